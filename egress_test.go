@@ -0,0 +1,65 @@
+package pushbell
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gootsolution/pushbell/pkg/httpclient"
+)
+
+// funcClientV2 is a custom ClientV2.
+type funcClientV2 func(req *httpclient.Request) (*httpclient.Response, error)
+
+func (f funcClientV2) Do(req *httpclient.Request) (*httpclient.Response, error) {
+	return f(req)
+}
+
+func TestNewServiceEgress(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	rootCAs := server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+
+	// The server is trusted only with the egress CAs, so pushes succeed only if
+	// they're applied to the client delivering them.
+	tests := map[string]*Options{
+		"v2":      NewOptions().SetHttpClientV2(httpclient.StdHttp(nil)),
+		"v1 & v2": NewOptions().SetHttpClient(&recordingClient{}).SetHttpClientV2(httpclient.StdHttp(nil)),
+		"v1":      NewOptions().SetHttpClient(httpclient.StdHttp(nil)),
+	}
+
+	for name, options := range tests {
+		t.Run(name, func(t *testing.T) {
+			pb, err := NewService(options.ApplyKeys(testPublicKey, testPrivateKey).SetRootCAs(rootCAs))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pb.Close()
+
+			push := testPush(t)
+			push.Endpoint = server.URL
+
+			result, err := pb.Deliver(push)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result.StatusCode != http.StatusCreated {
+				t.Fatalf("status %d, want %d", result.StatusCode, http.StatusCreated)
+			}
+		})
+	}
+
+	custom := funcClientV2(func(*httpclient.Request) (*httpclient.Response, error) {
+		return &httpclient.Response{StatusCode: http.StatusCreated}, nil
+	})
+
+	_, err := NewService(NewOptions().ApplyKeys(testPublicKey, testPrivateKey).SetHttpClientV2(custom).SetRootCAs(rootCAs))
+	if !errors.Is(err, httpclient.ErrEgressUnsupported) {
+		t.Fatalf("got error %v, want %v", err, httpclient.ErrEgressUnsupported)
+	}
+}
@@ -7,11 +7,7 @@ retract (
 	v1.0.0 // Broken encryption service
 )
 
-require (
-	github.com/golang-jwt/jwt/v5 v5.2.2
-	github.com/valyala/fasthttp v1.59.0
-	golang.org/x/crypto v0.36.0
-)
+require github.com/valyala/fasthttp v1.59.0
 
 require (
 	github.com/andybalholm/brotli v1.1.1 // indirect
@@ -0,0 +1,348 @@
+package pushbell
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gootsolution/pushbell/pkg/utils"
+	"github.com/gootsolution/pushbell/pkg/vapid"
+)
+
+var (
+	// ErrUnknownIdentity is returned when no VAPID identity matches the push.
+	ErrUnknownIdentity = errors.New("unknown VAPID identity")
+	// ErrDuplicateIdentity is returned when identities share an ID or a public key.
+	ErrDuplicateIdentity = errors.New("duplicate VAPID identity")
+	// ErrCurrentKey is returned when retiring the current key of an identity.
+	ErrCurrentKey = errors.New("current key of the identity can't be retired")
+)
+
+// Identity is an application server identity, a VAPID key pair with its subject,
+// e.g. of one of several brands served by the same service.
+type Identity struct {
+	ID         string        // Identifier used to select the identity with Push.Identity.
+	PublicKey  string        // [RFC 8292] ECDH public key in any format supported by Options.ApplyKeys. Derived from the private key if empty.
+	PrivateKey string        // [RFC 8292] ECDH private key in any format supported by Options.ApplyKeys.
+	Signer     crypto.Signer // [Optional] Signer used instead of PrivateKey, see Options.ApplySigner.
+	Subject    string        // [Optional] Either a "mailto:" (email) or a "https:" URI, Options.ApplicationServerSubject if empty, kept by Service.RotateIdentity.
+}
+
+// IdentityKey describes an active key of an identity.
+type IdentityKey struct {
+	ID        string // Identity ID, empty for the default identity.
+	PublicKey string // URL-safe base64 public key, as given to subscribers as applicationServerKey.
+	Current   bool   // Whether the key is current, rather than replaced by rotation and kept for existing subscriptions.
+	StaleUses uint64 // Number of pushes signed with the key since it was replaced.
+}
+
+// identityKey is an active key of an identity.
+type identityKey struct {
+	id      string
+	subject string // Subject the key signs tokens with, the default for rotation.
+	service *vapid.Service
+	uses    atomic.Uint64
+}
+
+// info returns description of the key.
+func (k *identityKey) info(current bool) IdentityKey {
+	return IdentityKey{
+		ID:        k.id,
+		PublicKey: k.service.PublicKey(),
+		Current:   current,
+		StaleUses: k.uses.Load(),
+	}
+}
+
+// identities keeps active keys of identities: the current one by ID, and all by public key.
+type identities struct {
+	mu      sync.RWMutex
+	current map[string]*identityKey
+	byKey   map[string]*identityKey
+
+	defaultID string // ID of the identity used for empty ID.
+}
+
+// newIdentities creates an empty identity set.
+func newIdentities() *identities {
+	return &identities{
+		current: make(map[string]*identityKey),
+		byKey:   make(map[string]*identityKey),
+	}
+}
+
+// Add registers a new identity with the given ID.
+func (i *identities) Add(id, subject string, service *vapid.Service) error {
+	key, err := normalizeKey(service.PublicKey())
+	if err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if _, ok := i.current[id]; ok {
+		return fmt.Errorf("%w: id %q", ErrDuplicateIdentity, id)
+	}
+
+	if _, ok := i.byKey[key]; ok {
+		return fmt.Errorf("%w: public key of %q", ErrDuplicateIdentity, id)
+	}
+
+	k := &identityKey{id: id, subject: subject, service: service}
+	i.current[id] = k
+	i.byKey[key] = k
+
+	return nil
+}
+
+// Rotate makes the service current for the identity with the given ID,
+// keeping the previous key active for existing subscriptions.
+func (i *identities) Rotate(id, subject string, service *vapid.Service) error {
+	key, err := normalizeKey(service.PublicKey())
+	if err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if id == "" {
+		id = i.defaultID
+	}
+
+	if _, ok := i.current[id]; !ok {
+		return fmt.Errorf("%w: id %q", ErrUnknownIdentity, id)
+	}
+
+	if _, ok := i.byKey[key]; ok {
+		return fmt.Errorf("%w: public key of %q", ErrDuplicateIdentity, id)
+	}
+
+	k := &identityKey{id: id, subject: subject, service: service}
+	i.current[id] = k
+	i.byKey[key] = k
+
+	return nil
+}
+
+// Retire removes a replaced key, so pushes to subscriptions with it fail.
+func (i *identities) Retire(publicKey string) error {
+	key, err := normalizeKey(publicKey)
+	if err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	k, ok := i.byKey[key]
+	if !ok {
+		return fmt.Errorf("%w: application server key %q", ErrUnknownIdentity, publicKey)
+	}
+
+	if i.current[k.id] == k {
+		return fmt.Errorf("%w: id %q", ErrCurrentKey, k.id)
+	}
+
+	delete(i.byKey, key)
+
+	return nil
+}
+
+// ByID returns the current key of the identity with the ID.
+func (i *identities) ByID(id string) (*identityKey, error) {
+	i.mu.RLock()
+	if id == "" {
+		id = i.defaultID
+	}
+	k, ok := i.current[id]
+	i.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: id %q", ErrUnknownIdentity, id)
+	}
+
+	return k, nil
+}
+
+// ByKey returns the key with the public key, given as applicationServerKey of a
+// subscription in any base64 encoding, and whether it has been replaced.
+func (i *identities) ByKey(publicKey string) (*identityKey, bool, error) {
+	key, err := normalizeKey(publicKey)
+	if err != nil {
+		return nil, false, err
+	}
+
+	i.mu.RLock()
+	k, ok := i.byKey[key]
+	stale := ok && i.current[k.id] != k
+	i.mu.RUnlock()
+
+	if !ok {
+		return nil, false, fmt.Errorf("%w: application server key %q", ErrUnknownIdentity, publicKey)
+	}
+
+	return k, stale, nil
+}
+
+// Keys returns descriptions of all active keys ordered by identity ID.
+func (i *identities) Keys() []IdentityKey {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	keys := make([]IdentityKey, 0, len(i.byKey))
+
+	for _, k := range i.byKey {
+		keys = append(keys, k.info(i.current[k.id] == k))
+	}
+
+	slices.SortFunc(keys, func(a, b IdentityKey) int {
+		if c := strings.Compare(a.ID, b.ID); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.PublicKey, b.PublicKey)
+	})
+
+	return keys
+}
+
+// TokenCacheStats returns token cache counters of all active keys summed per identity ID.
+func (i *identities) TokenCacheStats() map[string]vapid.CacheStats {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	stats := make(map[string]vapid.CacheStats, len(i.current))
+
+	for _, k := range i.byKey {
+		keyStats := k.service.CacheStats()
+		total := stats[k.id]
+		total.Hits += keyStats.Hits
+		total.Misses += keyStats.Misses
+		total.Entries += keyStats.Entries
+		stats[k.id] = total
+	}
+
+	return stats
+}
+
+// normalizeKey normalizes base64 public key, so that keys stored with different
+// base64 alphabets or padding match.
+func normalizeKey(publicKey string) (string, error) {
+	key, err := utils.ParseBase64Key(publicKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode application server key: %w", err)
+	}
+
+	return string(key), nil
+}
+
+// AddIdentity registers an additional VAPID identity, see Options.AddIdentity.
+func (s *Service) AddIdentity(identity Identity) error {
+	if identity.ID == "" {
+		return fmt.Errorf("%w: empty id", ErrUnknownIdentity)
+	}
+
+	options := s.options.vapidOptions(&identity)
+
+	service, err := vapid.NewServiceWithOptions(options)
+	if err != nil {
+		return fmt.Errorf("failed to create vapid service of identity %q: %w", identity.ID, err)
+	}
+
+	return s.identities.Add(identity.ID, options.Subject, service)
+}
+
+// RotateIdentity replaces the current key pair of the identity with identity.ID,
+// or of the default identity if the ID is empty. New subscriptions must use the
+// new public key, while pushes to subscriptions created with the previous key
+// are still signed with it, if Push.ApplicationServerKey is set. Once such
+// subscriptions are migrated or gone, see IdentityKeys and
+// Options.SetStaleKeyCallback, remove the previous key with RetireKey.
+// The subject of the identity is kept if identity.Subject is empty.
+func (s *Service) RotateIdentity(identity Identity) error {
+	if identity.Subject == "" {
+		current, err := s.identities.ByID(identity.ID)
+		if err != nil {
+			return err
+		}
+
+		identity.Subject = current.subject
+	}
+
+	options := s.options.vapidOptions(&identity)
+
+	service, err := vapid.NewServiceWithOptions(options)
+	if err != nil {
+		return fmt.Errorf("failed to create vapid service of identity %q: %w", identity.ID, err)
+	}
+
+	return s.identities.Rotate(identity.ID, options.Subject, service)
+}
+
+// RetireKey removes a key replaced by RotateIdentity. Pushes to subscriptions
+// still using it fail with ErrUnknownIdentity.
+func (s *Service) RetireKey(publicKey string) error {
+	return s.identities.Retire(publicKey)
+}
+
+// CurrentKey returns the current public key of the identity with the ID, or of the
+// default identity if the ID is empty, to be given to new subscribers.
+func (s *Service) CurrentKey(id string) (string, error) {
+	k, err := s.identities.ByID(id)
+	if err != nil {
+		return "", err
+	}
+
+	return k.service.PublicKey(), nil
+}
+
+// IdentityKeys returns all active keys, including replaced ones not retired yet.
+func (s *Service) IdentityKeys() []IdentityKey {
+	return s.identities.Keys()
+}
+
+// TokenCacheStats returns VAPID token cache counters per identity ID, the empty
+// ID being the default identity, including keys replaced but not retired yet.
+// Stats are zero unless Options.TokenRefreshMargin is set.
+func (s *Service) TokenCacheStats() map[string]vapid.CacheStats {
+	return s.identities.TokenCacheStats()
+}
+
+// vapid returns VAPID service for the push: of the key matching
+// Push.ApplicationServerKey, which must belong to Push.Identity if both are set,
+// or the current one of Push.Identity, or of the default identity.
+func (s *Service) vapid(push *Push) (*vapid.Service, error) {
+	if push.ApplicationServerKey == "" {
+		k, err := s.identities.ByID(push.Identity)
+		if err != nil {
+			return nil, err
+		}
+
+		return k.service, nil
+	}
+
+	k, stale, err := s.identities.ByKey(push.ApplicationServerKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if push.Identity != "" && k.id != push.Identity {
+		return nil, fmt.Errorf("%w: application server key doesn't belong to %q", ErrUnknownIdentity, push.Identity)
+	}
+
+	if stale {
+		k.uses.Add(1)
+
+		if s.options.OnStaleKey != nil {
+			s.options.OnStaleKey(push, k.info(false))
+		}
+	}
+
+	return k.service, nil
+}
@@ -0,0 +1,282 @@
+package pushbell
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gootsolution/pushbell/pkg/httpclient"
+	"github.com/gootsolution/pushbell/pkg/vapid"
+)
+
+const (
+	testPublicKey  = "BIRM67G3W1fva-ephDo220BbiaOOy-SBk2uzHsmlqMXp_OmkKxYW96cOK5EWnKdkLg2i7N4FYfuxIwm7JWThVSY"
+	testPrivateKey = "QxfAyO5dMMrSvDT2_xHxW5aktYPWGE_hT42RKlHilpQ"
+)
+
+// recordingClient records headers of the last request.
+type recordingClient struct {
+	headers *httpclient.Headers
+}
+
+func (c *recordingClient) RequestDelivery(_ string, headers *httpclient.Headers, _ *bytes.Buffer) (int, error) {
+	c.headers = headers
+
+	return http.StatusCreated, nil
+}
+
+// testPush returns push to a new subscription.
+func testPush(t *testing.T) *Push {
+	t.Helper()
+
+	uaPrivateKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authSecret := make([]byte, 16)
+	_, _ = rand.Read(authSecret)
+
+	return &Push{
+		Endpoint:  "https://push.example.com/subscription",
+		Auth:      base64.RawURLEncoding.EncodeToString(authSecret),
+		P256DH:    base64.RawURLEncoding.EncodeToString(uaPrivateKey.PublicKey().Bytes()),
+		Plaintext: []byte("hello"),
+	}
+}
+
+func TestSendIdentity(t *testing.T) {
+	brand, err := vapid.GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := new(recordingClient)
+
+	pb, err := NewService(NewOptions().
+		ApplyKeys(testPublicKey, testPrivateKey).
+		AddIdentity(Identity{ID: "brand", PrivateKey: brand.PrivateKeyBase64(), Subject: "mailto:push@brand.example"}).
+		SetHttpClient(client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pb.Close()
+
+	// applicationServerKey stored with the standard base64 alphabet and padding.
+	brandKey := base64.StdEncoding.EncodeToString(brand.PublicKeyBytes())
+
+	tests := map[string]struct {
+		identity string
+		key      string
+		want     string
+		err      error
+	}{
+		"default":        {want: testPublicKey},
+		"by id":          {identity: "brand", want: brand.PublicKeyBase64()},
+		"by key":         {key: brandKey, want: brand.PublicKeyBase64()},
+		"default by key": {key: testPublicKey, want: testPublicKey},
+		"unknown id":     {identity: "other", err: ErrUnknownIdentity},
+		"unknown key":    {key: base64.RawURLEncoding.EncodeToString(make([]byte, 65)), err: ErrUnknownIdentity},
+		"key of another": {identity: "brand", key: testPublicKey, err: ErrUnknownIdentity},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			push := testPush(t)
+			push.Identity = tt.identity
+			push.ApplicationServerKey = tt.key
+
+			err := pb.Send(push)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+
+			if tt.err != nil {
+				return
+			}
+
+			if !strings.HasSuffix(client.headers.Authorization, ", k="+tt.want) {
+				t.Fatalf("signed with a wrong key: %s", client.headers.Authorization)
+			}
+		})
+	}
+}
+
+func TestNewServiceDuplicateIdentity(t *testing.T) {
+	_, err := NewService(NewOptions().
+		ApplyKeys(testPublicKey, testPrivateKey).
+		AddIdentity(Identity{ID: "copy", PrivateKey: testPrivateKey}))
+	if !errors.Is(err, ErrDuplicateIdentity) {
+		t.Fatalf("got %v, want %v", err, ErrDuplicateIdentity)
+	}
+}
+
+func TestRotateIdentity(t *testing.T) {
+	next, err := vapid.GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := new(recordingClient)
+
+	var stale []IdentityKey
+
+	pb, err := NewService(NewOptions().
+		ApplyKeys(testPublicKey, testPrivateKey).
+		SetStaleKeyCallback(func(_ *Push, key IdentityKey) { stale = append(stale, key) }).
+		SetHttpClient(client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pb.Close()
+
+	if err = pb.RotateIdentity(Identity{PrivateKey: next.PrivateKeyBase64()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if current, _ := pb.CurrentKey(""); current != next.PublicKeyBase64() {
+		t.Fatalf("current key %s, want %s", current, next.PublicKeyBase64())
+	}
+
+	// New subscriptions use the new key, old ones keep the key they were created with.
+	for _, key := range []string{"", next.PublicKeyBase64(), testPublicKey} {
+		push := testPush(t)
+		push.ApplicationServerKey = key
+
+		if err = pb.Send(push); err != nil {
+			t.Fatal(err)
+		}
+
+		want := key
+		if want == "" {
+			want = next.PublicKeyBase64()
+		}
+
+		if !strings.HasSuffix(client.headers.Authorization, ", k="+want) {
+			t.Fatalf("signed with a wrong key: %s", client.headers.Authorization)
+		}
+	}
+
+	if len(stale) != 1 || stale[0].PublicKey != testPublicKey || stale[0].StaleUses != 1 {
+		t.Fatalf("unexpected stale key reports %+v", stale)
+	}
+
+	if keys := pb.IdentityKeys(); len(keys) != 2 {
+		t.Fatalf("unexpected keys %+v", keys)
+	}
+
+	if err = pb.RetireKey(next.PublicKeyBase64()); !errors.Is(err, ErrCurrentKey) {
+		t.Fatalf("got %v, want %v", err, ErrCurrentKey)
+	}
+
+	if err = pb.RetireKey(testPublicKey); err != nil {
+		t.Fatal(err)
+	}
+
+	push := testPush(t)
+	push.ApplicationServerKey = testPublicKey
+
+	if err = pb.Send(push); !errors.Is(err, ErrUnknownIdentity) {
+		t.Fatalf("got %v, want %v", err, ErrUnknownIdentity)
+	}
+}
+
+func TestTokenCacheStats(t *testing.T) {
+	brand, err := vapid.GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pb, err := NewService(NewOptions().
+		ApplyKeys(testPublicKey, testPrivateKey).
+		AddIdentity(Identity{ID: "brand", PrivateKey: brand.PrivateKeyBase64()}).
+		SetTokenCacheEnabled().
+		SetHttpClient(new(recordingClient)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pb.Close()
+
+	for _, identity := range []string{"", "brand", "brand"} {
+		push := testPush(t)
+		push.Identity = identity
+
+		if err = pb.Send(push); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := pb.TokenCacheStats()
+
+	if got := stats[""]; got != (vapid.CacheStats{Misses: 1, Entries: 1}) {
+		t.Fatalf("unexpected default identity stats %+v", got)
+	}
+
+	if got := stats["brand"]; got != (vapid.CacheStats{Hits: 1, Misses: 1, Entries: 1}) {
+		t.Fatalf("unexpected brand identity stats %+v", got)
+	}
+}
+
+func TestRotateIdentityKeepsSubject(t *testing.T) {
+	brand, err := vapid.GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := vapid.GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := new(recordingClient)
+
+	pb, err := NewService(NewOptions().
+		ApplyKeys(testPublicKey, testPrivateKey).
+		AddIdentity(Identity{ID: "brand", PrivateKey: brand.PrivateKeyBase64(), Subject: "mailto:push@brand.example"}).
+		SetHttpClient(client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pb.Close()
+
+	if err = pb.RotateIdentity(Identity{ID: "brand", PrivateKey: next.PrivateKeyBase64()}); err != nil {
+		t.Fatal(err)
+	}
+
+	push := testPush(t)
+	push.Identity = "brand"
+
+	if err = pb.Send(push); err != nil {
+		t.Fatal(err)
+	}
+
+	token, _, _ := strings.Cut(strings.TrimPrefix(client.headers.Authorization, "vapid t="), ",")
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("unexpected token %s", token)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var claims struct {
+		Sub string `json:"sub"`
+	}
+
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.Sub != "mailto:push@brand.example" {
+		t.Fatalf("subject %q after rotation", claims.Sub)
+	}
+}
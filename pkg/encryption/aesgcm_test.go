@@ -0,0 +1,181 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// legacyDecrypt decrypts aesgcm payload with the salt and sender key of its headers,
+// independently of the encryption code under test.
+func legacyDecrypt(t *testing.T, payload *Payload, uaPrivateKey *ecdh.PrivateKey, authSecret []byte) ([]byte, error) {
+	t.Helper()
+
+	salt, ok := strings.CutPrefix(payload.EncryptionHeader(), "salt=")
+	if !ok {
+		t.Fatalf("malformed Encryption header %q", payload.EncryptionHeader())
+	}
+
+	dh, ok := strings.CutPrefix(payload.CryptoKeyHeader(), "dh=")
+	if !ok {
+		t.Fatalf("malformed Crypto-Key header %q", payload.CryptoKeyHeader())
+	}
+
+	asPublicKey, err := ecdh.P256().NewPublicKey(decode(t, dh))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sharedSecret, err := uaPrivateKey.ECDH(asPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ikm, err := hkdf.Key(sha256.New, sharedSecret, authSecret, "Content-Encoding: auth\x00", 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var context []byte
+	context = append(context, "P-256\x00"...)
+	context = binary.BigEndian.AppendUint16(context, 65)
+	context = append(context, uaPrivateKey.PublicKey().Bytes()...)
+	context = binary.BigEndian.AppendUint16(context, 65)
+	context = append(context, asPublicKey.Bytes()...)
+
+	cek, err := hkdf.Key(sha256.New, ikm, decode(t, salt), "Content-Encoding: aesgcm\x00"+string(context), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nonce, err := hkdf.Key(sha256.New, ikm, decode(t, salt), "Content-Encoding: nonce\x00"+string(context), 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := gcm.Open(nil, nonce, payload.Body.Bytes(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	padLen := int(binary.BigEndian.Uint16(record))
+	if 2+padLen > len(record) || bytes.ContainsFunc(record[2:2+padLen], func(r rune) bool { return r != 0 }) {
+		return nil, errors.New("invalid padding")
+	}
+
+	return record[2+padLen:], nil
+}
+
+func TestLegacyEncrypt(t *testing.T) {
+	s, err := NewServiceWithOptions(&Options{
+		KeyMode:    KeyModeShared,
+		PrivateKey: privateKey(t, rfcASPrivate),
+		Random:     bytes.NewReader(decode(t, rfcSalt)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	payload, err := s.EncryptMessage(&Message{
+		Auth:      rfcAuthSecret,
+		P256DH:    rfcUAPublic,
+		Plaintext: []byte(rfcPlaintext),
+		Encoding:  AESGCM,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer payload.Release()
+
+	if header := payload.EncryptionHeader(); header != "salt="+rfcSalt {
+		t.Fatalf("Encryption header %q, want salt=%s", header, rfcSalt)
+	}
+
+	if header := payload.CryptoKeyHeader(); header != "dh="+rfcASPublic {
+		t.Fatalf("Crypto-Key header %q, want dh=%s", header, rfcASPublic)
+	}
+
+	// 2 octets padding length and 16 octets tag.
+	if payload.Body.Len() != len(rfcPlaintext)+18 {
+		t.Fatalf("body length %d, want %d", payload.Body.Len(), len(rfcPlaintext)+18)
+	}
+
+	plaintext, err := legacyDecrypt(t, payload, privateKey(t, rfcUAPrivate), decode(t, rfcAuthSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(plaintext) != rfcPlaintext {
+		t.Fatalf("plaintext %q, want %q", plaintext, rfcPlaintext)
+	}
+}
+
+func TestLegacyRoundTrip(t *testing.T) {
+	s, err := NewService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	uaPrivateKey, authSecret, auth, p256dh := newSubscription(t)
+
+	tests := []struct {
+		name    string
+		length  int
+		padding PaddingFunc
+		bodyLen int
+	}{
+		{"empty", 0, nil, 18},
+		{"padded", 100, PadToBuckets(1024), 1024 + 18},
+		{"max", maxLegacyPlaintextLen, nil, maxBodyLen},
+		{"max padded", maxLegacyPlaintextLen, PadToMax, maxBodyLen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := bytes.Repeat([]byte("a"), tt.length)
+
+			payload, err := s.EncryptMessage(&Message{Auth: auth, P256DH: p256dh, Plaintext: message, Encoding: AESGCM, Padding: tt.padding})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer payload.Release()
+
+			if payload.Body.Len() != tt.bodyLen {
+				t.Fatalf("body length %d, want %d", payload.Body.Len(), tt.bodyLen)
+			}
+
+			plaintext, err := legacyDecrypt(t, payload, uaPrivateKey, authSecret)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(plaintext, message) {
+				t.Fatalf("plaintext of %d octets, want %d", len(plaintext), len(message))
+			}
+		})
+	}
+
+	_, err = s.EncryptMessage(&Message{Auth: auth, P256DH: p256dh, Plaintext: make([]byte, maxLegacyPlaintextLen+1), Encoding: AESGCM})
+	if !errors.Is(err, ErrPayloadTooLarge) {
+		t.Fatalf("got error %v, want %v", err, ErrPayloadTooLarge)
+	}
+}
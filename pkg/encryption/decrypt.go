@@ -0,0 +1,76 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	headerMinLen  = 21 // 16 octets salt + 4 octets record size + 1 octet key id length.
+	tagLen        = 16 // AES-GCM authentication tag length.
+	minRecordSize = 18 // RFC 8188 2: record size MUST be greater than the tag plus a delimiter.
+)
+
+var (
+	// ErrTruncatedHeader is returned when ciphertext is shorter than its header.
+	ErrTruncatedHeader = errors.New("ciphertext is shorter than its aes128gcm header")
+	// ErrInvalidRecordSize is returned when header record size is below the RFC 8188 minimum.
+	ErrInvalidRecordSize = errors.New("record size must be at least 18 octets")
+	// ErrInvalidKeyID is returned when header key id is not an application server public key.
+	ErrInvalidKeyID = errors.New("key id is not an uncompressed P-256 public key")
+	// ErrTruncatedRecord is returned when ciphertext ends before the last record.
+	ErrTruncatedRecord = errors.New("ciphertext ends before the last record")
+	// ErrRecordAuthentication is returned when a record fails AES-GCM authentication.
+	ErrRecordAuthentication = errors.New("record authentication failed")
+	// ErrInvalidPadding is returned when a record has no valid padding delimiter.
+	ErrInvalidPadding = errors.New("record padding is invalid")
+	// ErrTrailingData is returned when ciphertext continues after the last record.
+	ErrTrailingData = errors.New("data after the last record")
+)
+
+// Decrypt decrypts aes128gcm ciphertext produced for a subscription, as the user agent
+// does. It parses RFC 8188 header, derives keys according to RFC 8291 with the
+// application server public key from the header key id and removes padding.
+func Decrypt(ciphertext []byte, uaPrivateKey *ecdh.PrivateKey, authSecret []byte) ([]byte, error) {
+	r := NewReader(bytes.NewReader(ciphertext), func(keyID []byte) ([]byte, error) {
+		asPublicKey, err := ecdh.P256().NewPublicKey(keyID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidKeyID, err)
+		}
+
+		sharedSecret, err := uaPrivateKey.ECDH(asPublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate shared secret: %w", err)
+		}
+
+		return prepareIKM(keyID, sharedSecret, authSecret, uaPrivateKey.PublicKey().Bytes())
+	})
+
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+// unpad removes RFC 8188 padding from a decrypted record and reports whether it is the last one.
+func unpad(record []byte) ([]byte, bool, error) {
+	for i := len(record) - 1; i >= 0; i-- {
+		switch record[i] {
+		case 0x00:
+			continue
+		case 0x01:
+			return record[:i], false, nil
+		case 0x02:
+			return record[:i], true, nil
+		default:
+			return nil, false, ErrInvalidPadding
+		}
+	}
+
+	return nil, false, ErrInvalidPadding
+}
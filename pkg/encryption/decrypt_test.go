@@ -0,0 +1,134 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+// newSubscription returns user agent private key, auth secret and their base64 forms.
+func newSubscription(t testing.TB) (*ecdh.PrivateKey, []byte, string, string) {
+	t.Helper()
+
+	uaPrivateKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authSecret := make([]byte, 16)
+	if _, err = rand.Read(authSecret); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := base64.RawURLEncoding.EncodeToString(authSecret)
+	p256dh := base64.RawURLEncoding.EncodeToString(uaPrivateKey.PublicKey().Bytes())
+
+	return uaPrivateKey, authSecret, auth, p256dh
+}
+
+func TestDecryptRoundTrip(t *testing.T) {
+	s, err := NewService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	uaPrivateKey, authSecret, auth, p256dh := newSubscription(t)
+
+	for _, plaintext := range [][]byte{
+		{},
+		[]byte("When I grow up, I want to be a watermelon"),
+		bytes.Repeat([]byte{0x02}, maxPlaintextLen),
+	} {
+		payload, err := s.EncryptMessage(&Message{Auth: auth, P256DH: p256dh, Plaintext: plaintext})
+		if err != nil {
+			t.Fatalf("encrypt %d octets: %v", len(plaintext), err)
+		}
+
+		decrypted, err := Decrypt(payload.Body.Bytes(), uaPrivateKey, authSecret)
+		if err != nil {
+			t.Fatalf("decrypt %d octets: %v", len(plaintext), err)
+		}
+
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Fatalf("decrypted %q, want %q", decrypted, plaintext)
+		}
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	s, err := NewService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	uaPrivateKey, authSecret, auth, p256dh := newSubscription(t)
+
+	ciphertext, err := s.Encrypt(auth, p256dh, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The buffer isn't pooled, so later messages don't overwrite it.
+	if _, err = s.Encrypt(auth, p256dh, []byte("other")); err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted, err := Decrypt(ciphertext.Bytes(), uaPrivateKey, authSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(decrypted) != "hello" {
+		t.Fatalf("decrypted %q, want %q", decrypted, "hello")
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	s, err := NewService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	uaPrivateKey, authSecret, auth, p256dh := newSubscription(t)
+
+	payload, err := s.EncryptMessage(&Message{Auth: auth, P256DH: p256dh, Plaintext: []byte("hello")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext := payload.Body.Bytes()
+
+	modify := func(f func(b []byte) []byte) []byte {
+		return f(bytes.Clone(ciphertext))
+	}
+
+	tests := []struct {
+		name       string
+		ciphertext []byte
+		authSecret []byte
+		want       error
+	}{
+		{"short header", ciphertext[:20], authSecret, ErrTruncatedHeader},
+		{"short key id", ciphertext[:50], authSecret, ErrTruncatedHeader},
+		{"small record size", modify(func(b []byte) []byte { b[16], b[17], b[18], b[19] = 0, 0, 0, 17; return b }), authSecret, ErrInvalidRecordSize},
+		{"bad key id", modify(func(b []byte) []byte { b[21] = 0x05; return b }), authSecret, ErrInvalidKeyID},
+		{"no records", ciphertext[:86], authSecret, ErrTruncatedRecord},
+		{"tampered record", modify(func(b []byte) []byte { b[len(b)-1] ^= 0xff; return b }), authSecret, ErrRecordAuthentication},
+		{"wrong auth secret", ciphertext, make([]byte, 16), ErrRecordAuthentication},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decrypt(tt.ciphertext, uaPrivateKey, tt.authSecret)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
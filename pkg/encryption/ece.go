@@ -0,0 +1,319 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultRecordSize is the record size used by web push, and a reasonable default for other content.
+const DefaultRecordSize = 4096
+
+var (
+	// ErrKeyIDTooLong is returned when key id doesn't fit into the one octet length field.
+	ErrKeyIDTooLong = errors.New("key id must not be longer than 255 octets")
+	// ErrWriterClosed is returned when writing to a closed Writer.
+	ErrWriterClosed = errors.New("write to closed writer")
+)
+
+// KeyFunc returns input keying material for the key id from the content header.
+type KeyFunc func(keyID []byte) ([]byte, error)
+
+// Writer encrypts content with the aes128gcm content encoding defined in RFC 8188.
+// Content is split into records of the configured size, so it can be of any length.
+// Close must be called to write the last record.
+type Writer struct {
+	w      io.Writer
+	gcm    cipher.AEAD
+	nonce  []byte
+	header []byte
+
+	buf []byte // plaintext of the pending record with room for delimiter and tag.
+	seq uint64
+	err error
+}
+
+// NewWriter returns a Writer encrypting content to w with the given input keying
+// material. Salt must be 16 random octets unique for ikm, keyID identifies ikm
+// for the receiver and may be empty, recordSize must be at least 18 octets.
+func NewWriter(w io.Writer, ikm, salt, keyID []byte, recordSize uint32) (*Writer, error) {
+	if len(salt) != 16 {
+		return nil, fmt.Errorf("salt must be 16 octets, got %d", len(salt))
+	}
+
+	if len(keyID) > 255 {
+		return nil, ErrKeyIDTooLong
+	}
+
+	if recordSize < minRecordSize {
+		return nil, fmt.Errorf("%w: got %d", ErrInvalidRecordSize, recordSize)
+	}
+
+	nonce, gcm, err := prepareNonceAndGCM(salt, ikm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare nonce and GCM: %w", err)
+	}
+
+	// Content coding header according to RFC8188 2.1:
+	// salt (16) || rs (4) || idlen (1) || keyid (idlen)
+	header := make([]byte, 0, headerMinLen+len(keyID))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, recordSize)
+	header = append(header, byte(len(keyID)))
+	header = append(header, keyID...)
+
+	return &Writer{
+		w:      w,
+		gcm:    gcm,
+		nonce:  nonce,
+		header: header,
+		buf:    make([]byte, 0, recordSize),
+	}, nil
+}
+
+// Write buffers p and writes every completed record to the underlying writer.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	// Room for plaintext in a record, excluding delimiter and tag.
+	capacity := cap(w.buf) - tagLen - 1
+
+	n := 0
+
+	for len(p) > 0 {
+		// The pending record is full and more content follows, so it isn't the last one.
+		if len(w.buf) == capacity {
+			if err := w.flush(0x01); err != nil {
+				return n, err
+			}
+		}
+
+		m := copy(w.buf[len(w.buf):capacity], p)
+		w.buf = w.buf[:len(w.buf)+m]
+		p = p[m:]
+		n += m
+	}
+
+	return n, nil
+}
+
+// Close writes the last record. It doesn't close the underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		if errors.Is(w.err, ErrWriterClosed) {
+			return nil
+		}
+
+		return w.err
+	}
+
+	if err := w.flush(0x02); err != nil {
+		return err
+	}
+
+	w.err = ErrWriterClosed
+
+	return nil
+}
+
+// flush encrypts and writes the pending record with the given padding delimiter.
+func (w *Writer) flush(delimiter byte) error {
+	if w.header != nil {
+		if _, err := w.w.Write(w.header); err != nil {
+			w.err = fmt.Errorf("failed to write header: %w", err)
+
+			return w.err
+		}
+
+		w.header = nil
+	}
+
+	record := append(w.buf, delimiter)
+	record = w.gcm.Seal(record[:0], recordNonce(w.nonce, w.seq), record, nil)
+
+	if _, err := w.w.Write(record); err != nil {
+		w.err = fmt.Errorf("failed to write record %d: %w", w.seq, err)
+
+		return w.err
+	}
+
+	w.buf = w.buf[:0]
+	w.seq++
+
+	return nil
+}
+
+// Reader decrypts content encrypted with the aes128gcm content encoding defined in RFC 8188.
+type Reader struct {
+	r     io.Reader
+	key   KeyFunc
+	gcm   cipher.AEAD
+	nonce []byte
+
+	keyID      []byte
+	recordSize uint32
+
+	record bytes.Buffer // encrypted record buffer.
+	data   []byte       // unread plaintext of the current record.
+	seq    uint64
+	last   bool
+	err    error
+}
+
+// NewReader returns a Reader decrypting content from r. The header is read on
+// the first Read, and key is called with its key id to get input keying material.
+func NewReader(r io.Reader, key KeyFunc) *Reader {
+	return &Reader{r: r, key: key}
+}
+
+// KeyID returns key id from the content header, or nil before the first Read.
+func (r *Reader) KeyID() []byte {
+	return r.keyID
+}
+
+// Read reads decrypted content into p.
+func (r *Reader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	for len(r.data) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		r.err = r.next()
+	}
+
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+
+	return n, nil
+}
+
+// next reads the header if needed and decrypts the next record.
+func (r *Reader) next() error {
+	if r.gcm == nil {
+		return r.readHeader()
+	}
+
+	if r.last {
+		// Nothing may follow the last record.
+		var b [1]byte
+
+		_, err := io.ReadFull(r.r, b[:])
+		switch {
+		case err == nil:
+			return ErrTrailingData
+		case errors.Is(err, io.EOF):
+			return io.EOF
+		default:
+			return fmt.Errorf("failed to read after the last record: %w", err)
+		}
+	}
+
+	// The buffer grows with the content read rather than the record size from
+	// the header, so untrusted headers can't cause large allocations.
+	r.record.Reset()
+
+	if _, err := r.record.ReadFrom(io.LimitReader(r.r, int64(r.recordSize))); err != nil {
+		return fmt.Errorf("failed to read record %d: %w", r.seq, err)
+	}
+
+	record := r.record.Bytes()
+	n := len(record)
+
+	if n < tagLen+1 {
+		return ErrTruncatedRecord
+	}
+
+	decrypted, err := r.gcm.Open(record[:0], recordNonce(r.nonce, r.seq), record, nil)
+	if err != nil {
+		return fmt.Errorf("%w: record %d", ErrRecordAuthentication, r.seq)
+	}
+
+	data, last, err := unpad(decrypted)
+	if err != nil {
+		return fmt.Errorf("%w: record %d", err, r.seq)
+	}
+
+	// Only the last record may be shorter than the record size.
+	if !last && n != int(r.recordSize) {
+		return ErrTruncatedRecord
+	}
+
+	r.data = data
+	r.last = last
+	r.seq++
+
+	return nil
+}
+
+// readHeader reads content coding header and prepares the cipher.
+func (r *Reader) readHeader() error {
+	header := make([]byte, headerMinLen)
+
+	if _, err := io.ReadFull(r.r, header); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return ErrTruncatedHeader
+		}
+
+		return fmt.Errorf("failed to read header: %w", err)
+	}
+
+	salt := header[:16]
+	recordSize := binary.BigEndian.Uint32(header[16:20])
+
+	if recordSize < minRecordSize {
+		return fmt.Errorf("%w: got %d", ErrInvalidRecordSize, recordSize)
+	}
+
+	keyID := make([]byte, header[20])
+
+	if _, err := io.ReadFull(r.r, keyID); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return ErrTruncatedHeader
+		}
+
+		return fmt.Errorf("failed to read key id: %w", err)
+	}
+
+	ikm, err := r.key(keyID)
+	if err != nil {
+		return fmt.Errorf("failed to get key: %w", err)
+	}
+
+	nonce, gcm, err := prepareNonceAndGCM(salt, ikm)
+	if err != nil {
+		return fmt.Errorf("failed to prepare nonce and GCM: %w", err)
+	}
+
+	r.keyID = keyID
+	r.recordSize = recordSize
+	r.nonce = nonce
+	r.gcm = gcm
+
+	return nil
+}
+
+// recordNonce calculates record nonce according to RFC8188 2.3:
+// NONCE = NONCE XOR SEQ, where SEQ is a 96-bit big endian record sequence number.
+func recordNonce(nonce []byte, seq uint64) []byte {
+	if seq == 0 {
+		return nonce
+	}
+
+	result := make([]byte, len(nonce))
+	copy(result, nonce)
+
+	for i := range 8 {
+		result[len(result)-1-i] ^= byte(seq >> (8 * i))
+	}
+
+	return result
+}
@@ -0,0 +1,109 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"testing/iotest"
+)
+
+func encode(t *testing.T, ikm, salt, keyID, plaintext []byte, recordSize uint32) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	w, err := NewWriter(&buf, ikm, salt, keyID, recordSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Write in small chunks to cross record boundaries.
+	for chunk := range slices.Chunk(plaintext, 7) {
+		if _, err = w.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestWriterReaderRoundTrip(t *testing.T) {
+	ikm := make([]byte, 16)
+	salt := make([]byte, 16)
+	_, _ = rand.Read(ikm)
+	_, _ = rand.Read(salt)
+
+	keyID := []byte("a1")
+
+	for _, recordSize := range []uint32{18, 25, DefaultRecordSize} {
+		for _, length := range []int{0, 1, 7, 8, 9, 100, 10000} {
+			plaintext := make([]byte, length)
+			_, _ = rand.Read(plaintext)
+
+			content := encode(t, ikm, salt, keyID, plaintext, recordSize)
+
+			r := NewReader(iotest.HalfReader(bytes.NewReader(content)), func(id []byte) ([]byte, error) {
+				if !bytes.Equal(id, keyID) {
+					t.Fatalf("key id %q, want %q", id, keyID)
+				}
+
+				return ikm, nil
+			})
+
+			decrypted, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("rs %d, length %d: %v", recordSize, length, err)
+			}
+
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Fatalf("rs %d, length %d: decrypted content mismatch", recordSize, length)
+			}
+		}
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	ikm := make([]byte, 16)
+	salt := make([]byte, 16)
+
+	// Three full records of 25 octets: 8 octets of content per record.
+	content := encode(t, ikm, salt, nil, bytes.Repeat([]byte("a"), 24), 25)
+	header := 21
+
+	key := func([]byte) ([]byte, error) { return ikm, nil }
+
+	tests := []struct {
+		name    string
+		content []byte
+		want    error
+	}{
+		{"missing last record", content[:header+50], ErrTruncatedRecord},
+		{"short last record", content[:len(content)-1], ErrRecordAuthentication},
+		{"trailing data", append(bytes.Clone(content), 0), ErrTrailingData},
+		{"reordered records", append(append(bytes.Clone(content[:header]), content[header+25:header+50]...), content[header:]...), ErrRecordAuthentication},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := io.ReadAll(NewReader(bytes.NewReader(tt.content), key))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// Read errors after the last record are wrapped rather than taken for the end of content.
+	errRead := errors.New("read failed")
+
+	_, err := io.ReadAll(NewReader(io.MultiReader(bytes.NewReader(content), iotest.ErrReader(errRead)), key))
+	if !errors.Is(err, errRead) {
+		t.Fatalf("got error %v, want %v", err, errRead)
+	}
+}
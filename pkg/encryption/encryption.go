@@ -5,9 +5,10 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/ecdh"
-	"crypto/rand"
 	"encoding/binary"
 	"fmt"
+	"io"
+	"slices"
 
 	"github.com/gootsolution/pushbell/pkg/utils"
 )
@@ -18,6 +19,12 @@ var (
 
 	// Generate nonce_info according to RFC8291 3.4:
 	nonceInfo = []byte("Content-Encoding: nonce\x00")
+
+	// Generate auth_info according to draft-ietf-webpush-encryption-04 3.3:
+	legacyAuthInfo = []byte("Content-Encoding: auth\x00")
+
+	// Generate cek_info according to draft-ietf-httpbis-encryption-encoding-03 4.2:
+	legacyCEKInfo = []byte("Content-Encoding: aesgcm\x00")
 )
 
 // prepareInputData return auth secret, user's public key, error.
@@ -36,13 +43,13 @@ func (s *Service) prepareInputData(auth, p256dh string) ([]byte, []byte, error)
 }
 
 // ecdhExchange return ECDH exchange return shared secret and error.
-func (s *Service) ecdhExchange(uaPublicKey []byte) ([]byte, error) {
+func (s *Service) ecdhExchange(keys *keyPair, uaPublicKey []byte) ([]byte, error) {
 	publicKey, err := ecdh.P256().NewPublicKey(uaPublicKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to pearse public key: %w", err)
 	}
 
-	sharedSecret, err := s.privateKey.ECDH(publicKey)
+	sharedSecret, err := keys.privateKey.ECDH(publicKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate shared secret: %w", err)
 	}
@@ -51,76 +58,152 @@ func (s *Service) ecdhExchange(uaPublicKey []byte) ([]byte, error) {
 }
 
 // prepareIKM return IKM and error.
-func (s *Service) prepareIKM(sharedSecret, authSecret, uaPublicKey []byte) ([]byte, error) {
+func prepareIKM(asPublicKey, sharedSecret, authSecret, uaPublicKey []byte) ([]byte, error) {
+	if len(uaPublicKey) != 65 || len(asPublicKey) != 65 {
+		return nil, fmt.Errorf("public keys must be 65 octets, got %d and %d", len(uaPublicKey), len(asPublicKey))
+	}
+
 	// Generate key_info according to RFC8291 3.4
-	keyInfo := make([]byte, 144)
+	var keyInfo [144]byte
 	copy(keyInfo[:14], "WebPush: info\x00")
 	copy(keyInfo[14:79], uaPublicKey)
-	copy(keyInfo[79:], s.publicKey)
+	copy(keyInfo[79:], asPublicKey)
 
 	// Generate PRK_key and IKM according to RFC8291 3.4:
-	// First 3 arguments to hkdf.New() response for PRK
 	//
 	// PRK = HMAC-SHA-256(auth_secret, ecdh_secret)
 	// IKM = HKDF-Expand(PRK, key_info, 32)
-	ikm, err := utils.HkdfExtractAndExpand(32, sharedSecret, authSecret, keyInfo)
+	prk, err := utils.HkdfExtract(sharedSecret, authSecret)
 	if err != nil {
-		return nil, fmt.Errorf("failed to extract and expand HKDF ikm: %w", err)
+		return nil, fmt.Errorf("failed to derive PRK: %w", err)
+	}
+
+	ikm, err := utils.HkdfExpand(32, prk, keyInfo[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive IKM: %w", err)
 	}
 
 	return ikm, nil
 }
 
-// prepareSalt return a 16-octet salt.
-func (s *Service) prepareSalt() ([]byte, error) {
-	salt := make([]byte, 0, 16)
+// prepareLegacyIKM return IKM for aesgcm encoding and error.
+func prepareLegacyIKM(sharedSecret, authSecret []byte) ([]byte, error) {
+	// Generate IKM according to draft-ietf-webpush-encryption-04 3.3:
+	//
+	// PRK = HMAC-SHA-256(auth_secret, ecdh_secret)
+	// IKM = HKDF-Expand(PRK, "Content-Encoding: auth" || 0x00, 32)
+	prk, err := utils.HkdfExtract(sharedSecret, authSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive PRK: %w", err)
+	}
+
+	ikm, err := utils.HkdfExpand(32, prk, legacyAuthInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive IKM: %w", err)
+	}
+
+	return ikm, nil
+}
+
+// prepareLegacyNonceAndGCM return ready to use nonce, GCM and error for aesgcm encoding.
+func prepareLegacyNonceAndGCM(asPublicKey, salt, ikm, uaPublicKey []byte) ([]byte, cipher.AEAD, error) {
+	// Generate context according to draft-ietf-webpush-encryption-04 3.4:
+	//
+	// context = "P-256" || 0x00 || len(ua_public) || ua_public || len(as_public) || as_public
+	context := make([]byte, 0, 140)
+	context = append(context, "P-256\x00"...)
+	context = binary.BigEndian.AppendUint16(context, uint16(len(uaPublicKey)))
+	context = append(context, uaPublicKey...)
+	context = binary.BigEndian.AppendUint16(context, uint16(len(asPublicKey)))
+	context = append(context, asPublicKey...)
+
+	prk, err := utils.HkdfExtract(ikm, salt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to derive PRK: %w", err)
+	}
+
+	// CEK = HKDF(salt, IKM, "Content-Encoding: aesgcm" || 0x00 || context, 16)
+	cek, err := utils.HkdfExpand(16, prk, slices.Concat(legacyCEKInfo, context))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to derive CEK: %w", err)
+	}
+
+	// NONCE = HKDF(salt, IKM, "Content-Encoding: nonce" || 0x00 || context, 12)
+	nonce, err := utils.HkdfExpand(12, prk, slices.Concat(nonceInfo, context))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to derive nonce: %w", err)
+	}
+
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nonce, gcm, nil
+}
 
-	if _, err := rand.Read(salt); err != nil {
-		return nil, fmt.Errorf("failed to generate salt: %w", err)
+// prepareSalt fill salt with 16 random octets.
+func (s *Service) prepareSalt(salt []byte) error {
+	if _, err := io.ReadFull(s.random, salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
 	}
 
-	return salt, nil
+	return nil
 }
 
 // prepareNonceAndGCM return ready to use nonce, GCM and error.
-func (s *Service) prepareNonceAndGCM(salt, ikm []byte) ([]byte, cipher.AEAD, error) {
-	// Generate CEK according to RFC8291 3.4:
-	// First 3 arguments to hkdf.New() response for PRK
+func prepareNonceAndGCM(salt, ikm []byte) ([]byte, cipher.AEAD, error) {
+	// PRK is shared by CEK and NONCE derivation according to RFC8291 3.4:
 	//
 	// PRK = HMAC-SHA-256(salt, IKM)
+	prk, err := utils.HkdfExtract(ikm, salt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to derive PRK: %w", err)
+	}
+
+	// Generate CEK according to RFC8291 3.4:
+	//
 	// CEK = HMAC-SHA-256(PRK, cek_info || 0x01)[0..15]
-	cek, err := utils.HkdfExtractAndExpand(16, ikm, salt, cekInfo)
+	cek, err := utils.HkdfExpand(16, prk, cekInfo)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to extract and expand HKDF nonce: %w", err)
+		return nil, nil, fmt.Errorf("failed to derive CEK: %w", err)
 	}
 
 	// Generate NONCE according to RFC8291 3.4:
-	// First 3 arguments to hkdf.New() response for PRK
 	//
-	// PRK = HMAC-SHA-256(salt, IKM)
 	// NONCE = HMAC-SHA-256(PRK, nonce_info || 0x01)[0..11]
-	nonce, err := utils.HkdfExtractAndExpand(12, ikm, salt, nonceInfo)
+	nonce, err := utils.HkdfExpand(12, prk, nonceInfo)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to derive nonce: %w", err)
+	}
+
+	gcm, err := newGCM(cek)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to extract and expand HKDF nonce: %w", err)
+		return nil, nil, err
 	}
 
+	return nonce, gcm, nil
+}
+
+// newGCM return AES-GCM cipher for content encryption key.
+func newGCM(cek []byte) (cipher.AEAD, error) {
 	// Cipher block
 	c, err := aes.NewCipher(cek)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create cipher: %w", err)
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
 	}
 
 	// GCM encryptor
 	gcm, err := cipher.NewGCMWithNonceSize(c, 12)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create GCM: %w", err)
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
 	}
 
-	return nonce, gcm, nil
+	return gcm, nil
 }
 
 // messageHeader generate and write an 86-octet header to buf.
-func (s *Service) messageHeader(buf *bytes.Buffer, salt []byte, recordSize uint32) {
+func (s *Service) messageHeader(buf *bytes.Buffer, keys *keyPair, salt []byte, recordSize uint32) {
 	// An 86-octet produced from the salt, record size of 4096, and application server public key
 	header := buf.AvailableBuffer()[:86]
 
@@ -134,14 +217,14 @@ func (s *Service) messageHeader(buf *bytes.Buffer, salt []byte, recordSize uint3
 	copy(header[20:], []byte{0x41})
 
 	// Writing application server public key defined in X9.62
-	copy(header[21:], s.publicKey)
+	copy(header[21:], keys.publicKey)
 
 	// Write header to dst buf.
 	buf.Write(header)
 }
 
 // messageBody prepare, cipher and write plaintext to buf.
-func (s *Service) messageBody(buf *bytes.Buffer, gcm cipher.AEAD, nonce, plaintext []byte) {
+func (s *Service) messageBody(buf *bytes.Buffer, gcm cipher.AEAD, nonce, plaintext []byte, padLen int) {
 	// Get available buffer for reuse.
 	pbuf := buf.AvailableBuffer()
 
@@ -151,6 +234,28 @@ func (s *Service) messageBody(buf *bytes.Buffer, gcm cipher.AEAD, nonce, plainte
 	// Append padding delimiter.
 	pbuf = append(pbuf, byte(0x02))
 
+	// Append zero padding after delimiter.
+	pbuf = append(pbuf, make([]byte, padLen)...)
+
+	// Encrypt the payload and get ciphertext.
+	pbuf = gcm.Seal(pbuf[:0], nonce, pbuf, nil)
+
+	// Write ciphertext to dst buf.
+	buf.Write(pbuf)
+}
+
+// legacyMessageBody prepare, cipher and write plaintext to buf according to aesgcm encoding.
+func (s *Service) legacyMessageBody(buf *bytes.Buffer, gcm cipher.AEAD, nonce, plaintext []byte, padLen int) {
+	// Get available buffer for reuse.
+	pbuf := buf.AvailableBuffer()
+
+	// Prepend padding length and zero padding, aesgcm places padding before the plaintext.
+	pbuf = binary.BigEndian.AppendUint16(pbuf, uint16(padLen))
+	pbuf = append(pbuf, make([]byte, padLen)...)
+
+	// Copy original plaintext.
+	pbuf = append(pbuf, plaintext...)
+
 	// Encrypt the payload and get ciphertext.
 	pbuf = gcm.Seal(pbuf[:0], nonce, pbuf, nil)
 
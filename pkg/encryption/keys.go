@@ -0,0 +1,142 @@
+package encryption
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// keyPair is an application server ECDH key pair.
+type keyPair struct {
+	privateKey *ecdh.PrivateKey
+	publicKey  []byte
+}
+
+// newKeyPair generates a new P-256 key pair with entropy from random.
+func newKeyPair(random io.Reader) (*keyPair, error) {
+	privateKey, err := generateKey(random)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+
+	return keyPairOf(privateKey), nil
+}
+
+// keyPairOf returns key pair of the private key.
+func keyPairOf(privateKey *ecdh.PrivateKey) *keyPair {
+	return &keyPair{
+		privateKey: privateKey,
+		publicKey:  privateKey.PublicKey().Bytes(),
+	}
+}
+
+// generateKey generates a P-256 private key. Unlike ecdh.Curve.GenerateKey, which
+// may ignore a custom entropy source, the scalar is read from random, so keys
+// depend only on it.
+func generateKey(random io.Reader) (*ecdh.PrivateKey, error) {
+	if random == nil || random == rand.Reader {
+		return ecdh.P256().GenerateKey(rand.Reader)
+	}
+
+	scalar := make([]byte, 32)
+
+	// A random scalar is out of the curve order range with probability below 2^-32,
+	// so a few attempts are enough for any sane entropy source.
+	for range 16 {
+		if _, err := io.ReadFull(random, scalar); err != nil {
+			return nil, fmt.Errorf("failed to read random: %w", err)
+		}
+
+		if privateKey, err := ecdh.P256().NewPrivateKey(scalar); err == nil {
+			return privateKey, nil
+		}
+	}
+
+	return nil, errors.New("failed to generate private key in range")
+}
+
+// Delays of key pool retries after key generation failures.
+const (
+	minFillBackoff = 10 * time.Millisecond
+	maxFillBackoff = 5 * time.Second
+)
+
+// keyPool keeps precomputed ephemeral key pairs, so that key generation
+// doesn't add to the latency of Encrypt under load.
+type keyPool struct {
+	random io.Reader
+
+	keys  chan *keyPair
+	done  chan struct{}
+	start sync.Once
+}
+
+// newKeyPool creates a pool of the given size, filled in background from the first Get.
+func newKeyPool(size int, random io.Reader) *keyPool {
+	return &keyPool{
+		random: random,
+		keys:   make(chan *keyPair, size),
+		done:   make(chan struct{}),
+	}
+}
+
+// fill generates key pairs until the pool is closed. Failures are retried with
+// backoff, while Get generates keys in place.
+func (p *keyPool) fill() {
+	backoff := minFillBackoff
+
+	for {
+		select {
+		case <-p.done:
+			return
+		default:
+		}
+
+		kp, err := newKeyPair(p.random)
+		if err != nil {
+			timer := time.NewTimer(backoff)
+
+			select {
+			case <-timer.C:
+			case <-p.done:
+				timer.Stop()
+
+				return
+			}
+
+			backoff = min(backoff*2, maxFillBackoff)
+
+			continue
+		}
+
+		backoff = minFillBackoff
+
+		select {
+		case p.keys <- kp:
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// Get returns a precomputed key pair, or generates one if the pool is drained.
+// The first call starts filling the pool.
+func (p *keyPool) Get() (*keyPair, error) {
+	p.start.Do(func() { go p.fill() })
+
+	select {
+	case kp := <-p.keys:
+		return kp, nil
+	default:
+		return newKeyPair(p.random)
+	}
+}
+
+// Close stops the background generation.
+func (p *keyPool) Close() {
+	close(p.done)
+}
@@ -0,0 +1,136 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEncryptEphemeralKeys(t *testing.T) {
+	tests := map[string]*Options{
+		"pool":         nil,
+		"without pool": {KeyPoolSize: -1},
+	}
+
+	_, _, auth, p256dh := newSubscription(t)
+
+	for name, options := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := NewServiceWithOptions(options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			var previous []byte
+
+			for range 3 {
+				payload, err := s.EncryptMessage(&Message{Auth: auth, P256DH: p256dh, Plaintext: []byte("hello")})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if bytes.Equal(payload.PublicKey, previous) {
+					t.Fatal("sender key is reused")
+				}
+
+				previous = bytes.Clone(payload.PublicKey)
+				payload.Release()
+			}
+		})
+	}
+}
+
+func TestKeyPool(t *testing.T) {
+	p := newKeyPool(2, nil)
+
+	if len(p.keys) != 0 {
+		t.Fatal("pool is filled before the first Get")
+	}
+
+	waitFull := func() {
+		t.Helper()
+
+		for deadline := time.Now().Add(time.Second); len(p.keys) != cap(p.keys); {
+			if time.Now().After(deadline) {
+				t.Fatal("pool isn't filled")
+			}
+
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	if _, err := p.Get(); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFull()
+
+	// Drained keys are replaced.
+	for range cap(p.keys) {
+		if _, err := p.Get(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	waitFull()
+
+	p.Close()
+	time.Sleep(20 * time.Millisecond)
+
+	// The closed pool stops filling, Get still generates keys in place.
+	<-p.keys
+	<-p.keys
+
+	time.Sleep(20 * time.Millisecond)
+
+	if len(p.keys) != 0 {
+		t.Fatal("closed pool is filled")
+	}
+
+	if _, err := p.Get(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// failingReader fails the first reads, then reads from crypto/rand.
+type failingReader struct {
+	failures atomic.Int32
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.failures.Add(-1) >= 0 {
+		return 0, errors.New("entropy source failed")
+	}
+
+	return rand.Read(p)
+}
+
+func TestKeyPoolRetry(t *testing.T) {
+	random := new(failingReader)
+	random.failures.Store(4)
+
+	p := newKeyPool(2, random)
+	defer p.Close()
+
+	// Get generates keys in place until the entropy source recovers.
+	_, _ = p.Get()
+
+	for deadline := time.Now().Add(2 * time.Second); len(p.keys) != cap(p.keys); {
+		if time.Now().After(deadline) {
+			t.Fatal("pool isn't filled after failures")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNewServiceRotationWithoutSharedKey(t *testing.T) {
+	_, err := NewServiceWithOptions(&Options{KeyRotationInterval: time.Hour})
+	if !errors.Is(err, ErrKeyRotationUnsupported) {
+		t.Fatalf("got error %v, want %v", err, ErrKeyRotationUnsupported)
+	}
+}
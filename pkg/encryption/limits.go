@@ -0,0 +1,96 @@
+package encryption
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrPayloadTooLarge is returned when plaintext doesn't fit into a push message.
+// Use errors.As with *PayloadTooLargeError to get the actual and allowed sizes.
+var ErrPayloadTooLarge = errors.New("payload too large")
+
+// PayloadTooLargeError describes plaintext that doesn't fit into a push message.
+type PayloadTooLargeError struct {
+	Encoding Encoding // Content encoding of the message.
+	Size     int      // Plaintext length.
+	Limit    int      // Maximum plaintext length for the encoding and padding.
+}
+
+func (e *PayloadTooLargeError) Error() string {
+	return fmt.Sprintf("%s: %d octets of plaintext, %s allows at most %d", ErrPayloadTooLarge, e.Size, e.Encoding, e.Limit)
+}
+
+func (e *PayloadTooLargeError) Unwrap() error {
+	return ErrPayloadTooLarge
+}
+
+// Excess returns the number of octets to trim from plaintext to fit.
+func (e *PayloadTooLargeError) Excess() int {
+	return e.Size - e.Limit
+}
+
+// plaintextLimit returns maximum unpadded plaintext length of a push message with encoding.
+func plaintextLimit(encoding Encoding) (int, error) {
+	switch encoding {
+	case AES128GCM, "":
+		return maxPlaintextLen, nil
+	case AESGCM:
+		return maxLegacyPlaintextLen, nil
+	default:
+		return 0, fmt.Errorf("unsupported content encoding: %q", encoding)
+	}
+}
+
+// MaxPlaintextLen returns maximum plaintext length of a push message with the
+// content encoding and padding strategy, nil padding means no padding. The
+// push message body is limited to 4096 octets, which for AES128GCM includes
+// an 86-octet header, a padding delimiter and a 16-octet authentication tag,
+// and for AESGCM a 2-octet padding length and the tag.
+//
+// Built-in strategies pad only up to the limit, so they don't reduce it. For
+// a custom strategy, the longest plaintext it accepts is searched, assuming
+// that a strategy accepting a length also accepts all shorter ones.
+func MaxPlaintextLen(encoding Encoding, padding PaddingFunc) (int, error) {
+	limit, err := plaintextLimit(encoding)
+	if err != nil {
+		return 0, err
+	}
+
+	fits := func(n int) bool {
+		_, err := paddingLen(padding, n, limit, zeroReader{})
+
+		return err == nil
+	}
+
+	if fits(limit) {
+		return limit, nil
+	}
+
+	// Binary search of the longest plaintext that fits: fits(lo) && !fits(hi).
+	lo, hi := -1, limit
+
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+
+		if fits(mid) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	if lo < 0 {
+		return 0, errors.New("padding doesn't accept any plaintext length")
+	}
+
+	return lo, nil
+}
+
+// zeroReader is an entropy source for deterministic padding length probes.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+
+	return len(p), nil
+}
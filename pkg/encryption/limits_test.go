@@ -0,0 +1,85 @@
+package encryption
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestMaxPlaintextLen(t *testing.T) {
+	// reserve is a custom padding that always adds at least 100 octets.
+	reserve := func(length, limit int, _ io.Reader) (int, error) {
+		if length+100 > limit {
+			return 0, errors.New("no room for padding")
+		}
+
+		return 100, nil
+	}
+
+	tests := []struct {
+		name     string
+		encoding Encoding
+		padding  PaddingFunc
+		want     int
+	}{
+		{"aes128gcm", AES128GCM, nil, 3993},
+		{"default encoding", "", PadNone, 3993},
+		{"aesgcm", AESGCM, nil, 4078},
+		{"buckets", AES128GCM, PadToBuckets(128, 1024), 3993},
+		{"max", AES128GCM, PadToMax, 3993},
+		{"random", AESGCM, PadRandom, 4078},
+		{"custom", AES128GCM, reserve, 3893},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MaxPlaintextLen(tt.encoding, tt.padding)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := MaxPlaintextLen("br", nil); err == nil {
+		t.Fatal("expected error for unsupported encoding")
+	}
+}
+
+func TestPayloadTooLarge(t *testing.T) {
+	s, err := NewService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	_, _, auth, p256dh := newSubscription(t)
+
+	for _, encoding := range []Encoding{AES128GCM, AESGCM} {
+		limit, _ := MaxPlaintextLen(encoding, nil)
+
+		payload, err := s.EncryptMessage(&Message{Auth: auth, P256DH: p256dh, Plaintext: bytes.Repeat([]byte("a"), limit), Encoding: encoding})
+		if err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+
+		if payload.Body.Len() != maxBodyLen {
+			t.Fatalf("%s: body length %d, want %d", encoding, payload.Body.Len(), maxBodyLen)
+		}
+
+		_, err = s.EncryptMessage(&Message{Auth: auth, P256DH: p256dh, Plaintext: bytes.Repeat([]byte("a"), limit+10), Encoding: encoding})
+
+		var tooLarge *PayloadTooLargeError
+		if !errors.As(err, &tooLarge) || !errors.Is(err, ErrPayloadTooLarge) {
+			t.Fatalf("%s: got error %v, want %v", encoding, err, ErrPayloadTooLarge)
+		}
+
+		if tooLarge.Size != limit+10 || tooLarge.Limit != limit || tooLarge.Excess() != 10 {
+			t.Fatalf("%s: unexpected error details %+v", encoding, tooLarge)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"sync"
+)
+
+// Encoding is a content encoding of an encrypted push message.
+type Encoding string
+
+const (
+	AES128GCM Encoding = "aes128gcm" // [RFC 8291] Content encoding.
+	AESGCM    Encoding = "aesgcm"    // [draft-ietf-webpush-encryption-04] Legacy content encoding for older user agents.
+)
+
+// Message is a push message to be encrypted for a subscription.
+type Message struct {
+	Auth      string      // Subscription auth secret.
+	P256DH    string      // Subscription public key.
+	Plaintext []byte      // Message content.
+	Encoding  Encoding    // [Optional] AES128GCM if empty.
+	Padding   PaddingFunc // [Optional] Padding strategy, service default if nil.
+}
+
+// Payload is an encrypted push message. Payloads are pooled: call Release
+// once Body is no longer referenced, and don't use the payload after that.
+type Payload struct {
+	Body      *bytes.Buffer // Ciphertext, including the header block for AES128GCM.
+	Encoding  Encoding      // Content encoding of Body.
+	Salt      []byte        // Salt, sent in the "Encryption" header for AESGCM.
+	PublicKey []byte        // Application server public key, sent in the "Crypto-Key" header for AESGCM.
+
+	salt [16]byte
+}
+
+// payloadPool reuses payloads with buffers large enough for any push message.
+var payloadPool = sync.Pool{
+	New: func() any {
+		return &Payload{Body: bytes.NewBuffer(make([]byte, 0, maxBodyLen))}
+	},
+}
+
+// newPayload returns an empty payload from the pool.
+func newPayload(encoding Encoding, publicKey []byte) *Payload {
+	p := payloadPool.Get().(*Payload)
+	p.Encoding = encoding
+	p.PublicKey = publicKey
+
+	return p
+}
+
+// Release returns the payload to the pool for reuse by later messages.
+// Releasing is optional, unreleased payloads are garbage collected.
+func (p *Payload) Release() {
+	p.Body.Reset()
+	p.Encoding = ""
+	p.Salt = nil
+	p.PublicKey = nil
+
+	payloadPool.Put(p)
+}
+
+// EncryptionHeader returns the value of "Encryption" header for AESGCM, empty string otherwise.
+func (p *Payload) EncryptionHeader() string {
+	if p.Encoding != AESGCM {
+		return ""
+	}
+
+	return "salt=" + base64.RawURLEncoding.EncodeToString(p.Salt)
+}
+
+// CryptoKeyHeader returns the value of "Crypto-Key" header for AESGCM, empty string otherwise.
+func (p *Payload) CryptoKeyHeader() string {
+	if p.Encoding != AESGCM {
+		return ""
+	}
+
+	return "dh=" + base64.RawURLEncoding.EncodeToString(p.PublicKey)
+}
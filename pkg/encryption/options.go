@@ -0,0 +1,32 @@
+package encryption
+
+import (
+	"crypto/ecdh"
+	"io"
+	"time"
+)
+
+// KeyMode defines how application server ECDH key pairs are chosen for messages.
+type KeyMode uint8
+
+const (
+	// KeyModeEphemeral uses a fresh key pair for every message, as RFC 8291 3.1 requires.
+	KeyModeEphemeral KeyMode = iota
+	// KeyModeShared reuses a single key pair for every message until it is rotated.
+	KeyModeShared
+)
+
+// DefaultKeyPoolSize is the number of precomputed ephemeral key pairs kept ready by default.
+const DefaultKeyPoolSize = 64
+
+// Options configures the encryption service.
+type Options struct {
+	KeyMode             KeyMode                // [Optional] Key pair mode, KeyModeEphemeral by default.
+	KeyPoolSize         int                    // [Optional] Number of precomputed ephemeral key pairs, DefaultKeyPoolSize if zero, disabled if negative.
+	KeyRotationInterval time.Duration          // [Optional] If set, enable shared key rotation. NewServiceWithOptions fails without KeyModeShared.
+	Padding             PaddingFunc            // [Optional] Default padding strategy for messages, PadNone if nil.
+	Random              io.Reader              // [Optional] Entropy source for keys, salts and padding, crypto/rand if nil. Must be safe for concurrent use.
+	PrivateKey          *ecdh.PrivateKey       // [Optional] Application server key pair for KeyModeShared, generated if nil. Replaced on rotation.
+	KeyRotationJitter   time.Duration          // [Optional] Maximum random delay added to every rotation interval, drawn from Random.
+	OnKeyRotation       func(KeyRotationEvent) // [Optional] Called after every shared key rotation attempt.
+}
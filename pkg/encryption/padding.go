@@ -0,0 +1,75 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+)
+
+// PaddingFunc returns the number of padding octets to add to a plaintext of
+// length octets. Padded length must not exceed limit, the maximum plaintext
+// length for the content encoding.
+type PaddingFunc func(length, limit int, random io.Reader) (int, error)
+
+// PadNone adds no padding, so ciphertext length reveals plaintext length.
+func PadNone(_, _ int, _ io.Reader) (int, error) {
+	return 0, nil
+}
+
+// PadToMax pads every plaintext to the maximum length, so all ciphertexts
+// have the same length at the cost of bandwidth.
+func PadToMax(length, limit int, _ io.Reader) (int, error) {
+	return limit - length, nil
+}
+
+// PadRandom adds a uniformly random amount of padding up to the maximum length.
+func PadRandom(length, limit int, random io.Reader) (int, error) {
+	if random == nil {
+		random = rand.Reader
+	}
+
+	n, err := rand.Int(random, big.NewInt(int64(limit-length+1)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to generate padding length: %w", err)
+	}
+
+	return int(n.Int64()), nil
+}
+
+// PadToBuckets pads plaintext to the smallest of sizes that fits it. Sizes
+// above the limit are ignored, and plaintexts longer than every size are
+// padded to the limit.
+func PadToBuckets(sizes ...int) PaddingFunc {
+	buckets := slices.Clone(sizes)
+	slices.Sort(buckets)
+
+	return func(length, limit int, _ io.Reader) (int, error) {
+		for _, size := range buckets {
+			if size >= length && size <= limit {
+				return size - length, nil
+			}
+		}
+
+		return limit - length, nil
+	}
+}
+
+// paddingLen calls padding and checks its result against limit.
+func paddingLen(padding PaddingFunc, length, limit int, random io.Reader) (int, error) {
+	if padding == nil {
+		return 0, nil
+	}
+
+	n, err := padding(length, limit, random)
+	if err != nil {
+		return 0, err
+	}
+
+	if n < 0 || length+n > limit {
+		return 0, fmt.Errorf("invalid padding length %d for %d octets plaintext (limit %d)", n, length, limit)
+	}
+
+	return n, nil
+}
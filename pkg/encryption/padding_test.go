@@ -0,0 +1,63 @@
+package encryption
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPadding(t *testing.T) {
+	s, err := NewService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	uaPrivateKey, authSecret, auth, p256dh := newSubscription(t)
+
+	tests := []struct {
+		name      string
+		padding   PaddingFunc
+		plaintext []byte
+		bodyLen   int
+	}{
+		{"none", PadNone, []byte("yes"), 86 + 3 + 1 + 16},
+		{"buckets", PadToBuckets(1024, 64), []byte("yes"), 86 + 64 + 1 + 16},
+		{"buckets overflow", PadToBuckets(64), bytes.Repeat([]byte("a"), 65), 4096},
+		{"max", PadToMax, []byte("no"), 4096},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := s.EncryptMessage(&Message{Auth: auth, P256DH: p256dh, Plaintext: tt.plaintext, Padding: tt.padding})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if payload.Body.Len() != tt.bodyLen {
+				t.Fatalf("body length %d, want %d", payload.Body.Len(), tt.bodyLen)
+			}
+
+			decrypted, err := Decrypt(payload.Body.Bytes(), uaPrivateKey, authSecret)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(decrypted, tt.plaintext) {
+				t.Fatalf("decrypted %q, want %q", decrypted, tt.plaintext)
+			}
+		})
+	}
+}
+
+func TestPadRandom(t *testing.T) {
+	for range 100 {
+		n, err := PadRandom(10, 20, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n < 0 || n > 10 {
+			t.Fatalf("padding length %d out of range", n)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"encoding/base64"
+	"testing"
+)
+
+// Example from RFC 8291 Appendix A.
+const (
+	rfcPlaintext  = "When I grow up, I want to be a watermelon"
+	rfcASPrivate  = "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"
+	rfcASPublic   = "BP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A8"
+	rfcUAPrivate  = "q1dXpw3UpT5VOmu_cf_v6ih07Aems3njxI-JWgLcM94"
+	rfcUAPublic   = "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"
+	rfcSalt       = "DGv6ra1nlYgDCS1FRnbzlw"
+	rfcAuthSecret = "BTBZMqHH6r4Tts7J_aSIgg"
+	rfcSharedECDH = "kyrL1jIIOHEzg3sM2ZWRHDRB62YACZhhSlknJ672kSs"
+	rfcIKM        = "S4lYMb_L0FxCeq0WhDx813KgSYqU26kOyzWUdsXYyrg"
+	rfcNonce      = "4h_95klXJ5E_qnoN"
+	rfcHeader     = "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A8"
+	rfcCiphertext = "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+	rfcCEK        = "oIhVW04MRdy2XN9CiKLxTg"
+)
+
+func decode(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func privateKey(t *testing.T, s string) *ecdh.PrivateKey {
+	t.Helper()
+
+	key, err := ecdh.P256().NewPrivateKey(decode(t, s))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func TestRFC8291KeyDerivation(t *testing.T) {
+	asPrivate := privateKey(t, rfcASPrivate)
+	uaPublic := decode(t, rfcUAPublic)
+
+	if got := asPrivate.PublicKey().Bytes(); !bytes.Equal(got, decode(t, rfcASPublic)) {
+		t.Fatalf("as_public %x, want %s", got, rfcASPublic)
+	}
+
+	s := &Service{}
+
+	sharedSecret, err := s.ecdhExchange(keyPairOf(asPrivate), uaPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(sharedSecret, decode(t, rfcSharedECDH)) {
+		t.Fatalf("ecdh_secret %x, want %s", sharedSecret, rfcSharedECDH)
+	}
+
+	ikm, err := prepareIKM(decode(t, rfcASPublic), sharedSecret, decode(t, rfcAuthSecret), uaPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(ikm, decode(t, rfcIKM)) {
+		t.Fatalf("IKM %x, want %s", ikm, rfcIKM)
+	}
+
+	nonce, gcm, err := prepareNonceAndGCM(decode(t, rfcSalt), ikm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(nonce, decode(t, rfcNonce)) {
+		t.Fatalf("NONCE %x, want %s", nonce, rfcNonce)
+	}
+
+	// CEK isn't exposed by cipher.AEAD, so check it by a ciphertext of the record.
+	want, err := newGCM(decode(t, rfcCEK))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := []byte(rfcPlaintext + "\x02")
+	if got := gcm.Seal(nil, nonce, record, nil); !bytes.Equal(got, want.Seal(nil, nonce, record, nil)) {
+		t.Fatalf("CEK mismatch")
+	}
+}
+
+func TestRFC8291Encrypt(t *testing.T) {
+	s, err := NewServiceWithOptions(&Options{
+		KeyMode:    KeyModeShared,
+		PrivateKey: privateKey(t, rfcASPrivate),
+		Random:     bytes.NewReader(decode(t, rfcSalt)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	payload, err := s.EncryptMessage(&Message{
+		Auth:      rfcAuthSecret,
+		P256DH:    rfcUAPublic,
+		Plaintext: []byte(rfcPlaintext),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := payload.Body.Bytes()
+
+	if header := base64.RawURLEncoding.EncodeToString(body[:86]); header != rfcHeader {
+		t.Fatalf("header\n%s\nwant\n%s", header, rfcHeader)
+	}
+
+	if got := base64.RawURLEncoding.EncodeToString(body); got != rfcCiphertext {
+		t.Fatalf("ciphertext\n%s\nwant\n%s", got, rfcCiphertext)
+	}
+}
+
+func TestRFC8291Decrypt(t *testing.T) {
+	plaintext, err := Decrypt(decode(t, rfcCiphertext), privateKey(t, rfcUAPrivate), decode(t, rfcAuthSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(plaintext) != rfcPlaintext {
+		t.Fatalf("plaintext %q, want %q", plaintext, rfcPlaintext)
+	}
+}
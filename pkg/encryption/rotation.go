@@ -0,0 +1,95 @@
+package encryption
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrKeyRotationUnsupported is returned when rotating keys of a service without a shared key.
+var ErrKeyRotationUnsupported = errors.New("key rotation requires KeyModeShared")
+
+// KeyRotationEvent describes a shared key rotation attempt.
+type KeyRotationEvent struct {
+	PreviousPublicKey []byte    // Application server public key before rotation.
+	PublicKey         []byte    // Application server public key after rotation, nil if rotation failed.
+	Time              time.Time // Time of rotation.
+	Err               error     // Error of key generation, previous key stays in use.
+}
+
+// PublicKey returns the current shared application server public key, or nil
+// without KeyModeShared.
+func (s *Service) PublicKey() []byte {
+	if keys := s.keys.Load(); keys != nil {
+		return keys.publicKey
+	}
+
+	return nil
+}
+
+// RotateNow replaces the shared key pair immediately. Messages being encrypted
+// keep using the key pair they started with.
+func (s *Service) RotateNow() error {
+	if s.mode != KeyModeShared {
+		return ErrKeyRotationUnsupported
+	}
+
+	event := KeyRotationEvent{Time: time.Now()}
+
+	keys, err := newKeyPair(s.random)
+	if err != nil {
+		event.PreviousPublicKey = s.PublicKey()
+		event.Err = err
+	} else {
+		event.PreviousPublicKey = s.keys.Swap(keys).publicKey
+		event.PublicKey = keys.publicKey
+	}
+
+	if s.onRotation != nil {
+		s.onRotation(event)
+	}
+
+	return err
+}
+
+// Rotate enables key rotation according to interval, with a random delay up to
+// the configured jitter added to every interval. It has effect only with KeyModeShared.
+func (s *Service) Rotate(interval time.Duration) {
+	if s.mode != KeyModeShared || interval <= 0 {
+		return
+	}
+
+	go func(s *Service) {
+		timer := time.NewTimer(s.rotationDelay(interval))
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-timer.C:
+				// Failure is reported to the rotation callback, the previous key stays in use.
+				_ = s.RotateNow()
+
+				timer.Reset(s.rotationDelay(interval))
+			case <-s.done:
+				return
+			}
+		}
+	}(s)
+}
+
+// rotationDelay returns interval with random jitter drawn from the entropy source.
+// If it fails, the interval is returned without jitter.
+func (s *Service) rotationDelay(interval time.Duration) time.Duration {
+	if s.rotationJitter <= 0 {
+		return interval
+	}
+
+	var b [8]byte
+	if _, err := io.ReadFull(s.random, b[:]); err != nil {
+		return interval
+	}
+
+	// Modulo bias is negligible for durations far below 2^64 nanoseconds.
+	return interval + time.Duration(binary.BigEndian.Uint64(b[:])%uint64(s.rotationJitter))
+}
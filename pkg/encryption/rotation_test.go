@@ -0,0 +1,115 @@
+package encryption
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRotateNow(t *testing.T) {
+	var events []KeyRotationEvent
+
+	s, err := NewServiceWithOptions(&Options{
+		KeyMode:       KeyModeShared,
+		OnKeyRotation: func(event KeyRotationEvent) { events = append(events, event) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	previous := s.PublicKey()
+
+	if err = s.RotateNow(); err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(s.PublicKey(), previous) {
+		t.Fatal("public key didn't change")
+	}
+
+	if len(events) != 1 || !bytes.Equal(events[0].PreviousPublicKey, previous) || !bytes.Equal(events[0].PublicKey, s.PublicKey()) {
+		t.Fatalf("unexpected rotation events %+v", events)
+	}
+}
+
+func TestRotateNowEphemeral(t *testing.T) {
+	s, err := NewService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err = s.RotateNow(); !errors.Is(err, ErrKeyRotationUnsupported) {
+		t.Fatalf("got error %v, want %v", err, ErrKeyRotationUnsupported)
+	}
+}
+
+func TestRotateConcurrentEncrypt(t *testing.T) {
+	s, err := NewServiceWithOptions(&Options{KeyMode: KeyModeShared})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	uaPrivateKey, authSecret, auth, p256dh := newSubscription(t)
+
+	var wg sync.WaitGroup
+
+	for range 4 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for range 50 {
+				payload, err := s.EncryptMessage(&Message{Auth: auth, P256DH: p256dh, Plaintext: []byte("hello")})
+				if err != nil {
+					t.Error(err)
+
+					return
+				}
+
+				if _, err = Decrypt(payload.Body.Bytes(), uaPrivateKey, authSecret); err != nil {
+					t.Error(err)
+				}
+
+				payload.Release()
+			}
+		}()
+	}
+
+	for range 20 {
+		if err = s.RotateNow(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wg.Wait()
+}
+
+func TestRotationDelayJitter(t *testing.T) {
+	random := bytes.NewReader([]byte{0, 0, 0, 0, 0, 0, 0x03, 0xe8})
+
+	s, err := NewServiceWithOptions(&Options{
+		KeyMode:           KeyModeShared,
+		PrivateKey:        privateKey(t, rfcASPrivate),
+		Random:            random,
+		KeyRotationJitter: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if delay := s.rotationDelay(time.Hour); delay != time.Hour+1000 {
+		t.Fatalf("delay %s, want 1h plus 1000ns", delay)
+	}
+
+	// Exhausted entropy source disables jitter.
+	if delay := s.rotationDelay(time.Hour); delay != time.Hour {
+		t.Fatalf("delay %s, want 1h", delay)
+	}
+}
@@ -4,88 +4,235 @@ import (
 	"bytes"
 	"crypto/ecdh"
 	"crypto/rand"
+	"errors"
 	"fmt"
+	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-const maxPlaintextLen = 3993
+const (
+	maxBodyLen            = 4096 // RFC 8030 7.2: push services must accept payloads of 4096 octets.
+	maxPlaintextLen       = 3993 // 4096 - 86 octets header - 16 octets tag - 1 octet padding delimiter.
+	maxLegacyPlaintextLen = 4078 // 4096 - 16 octets tag - 2 octets padding length.
+)
 
 type Service struct {
-	publicKey  []byte
-	privateKey *ecdh.PrivateKey
+	mode    KeyMode
+	pool    *keyPool
+	padding PaddingFunc
+	random  io.Reader
+
+	keys           atomic.Pointer[keyPair]
+	rotationJitter time.Duration
+	onRotation     func(KeyRotationEvent)
 
-	mu *sync.RWMutex
+	done chan struct{}
+	once *sync.Once
 }
 
+// NewService creates new encryption service with default options, see NewServiceWithOptions.
 func NewService() (*Service, error) {
-	privateKey, err := ecdh.P256().GenerateKey(rand.Reader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate private key: %v", err)
+	return NewServiceWithOptions(nil)
+}
+
+// NewServiceWithOptions creates new encryption service. If options is nil, defaults
+// are used: ephemeral key pair per message with a background key pool, started by
+// the first Encrypt. Close stops the background work.
+func NewServiceWithOptions(options *Options) (*Service, error) {
+	if options == nil {
+		options = &Options{}
+	}
+
+	if options.KeyRotationInterval != 0 && options.KeyMode != KeyModeShared {
+		return nil, fmt.Errorf("%w: KeyRotationInterval is set", ErrKeyRotationUnsupported)
+	}
+
+	random := options.Random
+	if random == nil {
+		random = rand.Reader
+	}
+
+	s := &Service{
+		mode:    options.KeyMode,
+		padding: options.Padding,
+		random:  random,
+		done:    make(chan struct{}),
+		once:    new(sync.Once),
+
+		rotationJitter: options.KeyRotationJitter,
+		onRotation:     options.OnKeyRotation,
 	}
 
-	publicKey := privateKey.PublicKey().Bytes()
+	switch options.KeyMode {
+	case KeyModeEphemeral:
+		size := options.KeyPoolSize
+		if size == 0 {
+			size = DefaultKeyPoolSize
+		}
+
+		if size > 0 {
+			s.pool = newKeyPool(size, options.Random)
+		}
+	case KeyModeShared:
+		if options.PrivateKey != nil {
+			if options.PrivateKey.Curve() != ecdh.P256() {
+				return nil, errors.New("private key must be on P-256 curve")
+			}
+
+			s.keys.Store(keyPairOf(options.PrivateKey))
+		} else {
+			keys, err := newKeyPair(options.Random)
+			if err != nil {
+				return nil, err
+			}
+
+			s.keys.Store(keys)
+		}
 
-	return &Service{
-		publicKey:  publicKey,
-		privateKey: privateKey,
-		mu:         new(sync.RWMutex),
-	}, nil
+		if options.KeyRotationInterval != 0 {
+			s.Rotate(options.KeyRotationInterval)
+		}
+	default:
+		return nil, fmt.Errorf("unknown key mode: %d", options.KeyMode)
+	}
+
+	return s, nil
 }
 
-// Encrypt return *bytes.Buffer with ciphertext.
+// Encrypt encrypts plaintext for the subscription with aes128gcm content encoding
+// and returns the ciphertext. Unlike EncryptMessage, the buffer isn't pooled.
 func (s *Service) Encrypt(auth, p256dh string, plaintext []byte) (*bytes.Buffer, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	payload, err := s.EncryptMessage(&Message{Auth: auth, P256DH: p256dh, Plaintext: plaintext})
+	if err != nil {
+		return nil, err
+	}
+
+	defer payload.Release()
+
+	return bytes.NewBuffer(bytes.Clone(payload.Body.Bytes())), nil
+}
+
+// EncryptMessage encrypts message with its content encoding and returns the payload.
+func (s *Service) EncryptMessage(msg *Message) (*Payload, error) {
+	encoding := msg.Encoding
+	if encoding == "" {
+		encoding = AES128GCM
+	}
+
+	limit, err := plaintextLimit(encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(msg.Plaintext) > limit {
+		return nil, &PayloadTooLargeError{Encoding: encoding, Size: len(msg.Plaintext), Limit: limit}
+	}
 
-	if len(plaintext) > maxPlaintextLen {
-		return nil, fmt.Errorf("plaintext too long (%d > %d)", len(plaintext), maxPlaintextLen)
+	padding := msg.Padding
+	if padding == nil {
+		padding = s.padding
 	}
 
-	authSecret, uaPublicKey, err := s.prepareInputData(auth, p256dh)
+	padLen, err := paddingLen(padding, len(msg.Plaintext), limit, s.random)
+	if err != nil {
+		// Custom padding may leave less room than the encoding does.
+		if maxLen, _ := MaxPlaintextLen(encoding, padding); len(msg.Plaintext) > maxLen {
+			return nil, &PayloadTooLargeError{Encoding: encoding, Size: len(msg.Plaintext), Limit: maxLen}
+		}
+
+		return nil, fmt.Errorf("failed to pad plaintext: %w", err)
+	}
+
+	keys, err := s.keyPair()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key pair: %w", err)
+	}
+
+	authSecret, uaPublicKey, err := s.prepareInputData(msg.Auth, msg.P256DH)
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare input data: %w", err)
 	}
 
-	sharedSecret, err := s.ecdhExchange(uaPublicKey)
+	sharedSecret, err := s.ecdhExchange(keys, uaPublicKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to exchange shared secret: %w", err)
 	}
 
-	ikm, err := s.prepareIKM(sharedSecret, authSecret, uaPublicKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to prepare ikm: %w", err)
+	payload := newPayload(encoding, keys.publicKey)
+
+	if err = s.encryptPayload(payload, keys, sharedSecret, authSecret, uaPublicKey, msg.Plaintext, padLen); err != nil {
+		payload.Release()
+
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+// encryptPayload encrypts plaintext into payload with its content encoding.
+func (s *Service) encryptPayload(payload *Payload, keys *keyPair, sharedSecret, authSecret, uaPublicKey, plaintext []byte, padLen int) error {
+	salt := payload.salt[:]
+
+	if err := s.prepareSalt(salt); err != nil {
+		return fmt.Errorf("failed to prepare salt: %w", err)
+	}
+
+	if payload.Encoding == AESGCM {
+		ikm, err := prepareLegacyIKM(sharedSecret, authSecret)
+		if err != nil {
+			return fmt.Errorf("failed to prepare ikm: %w", err)
+		}
+
+		nonce, gcm, err := prepareLegacyNonceAndGCM(keys.publicKey, salt, ikm, uaPublicKey)
+		if err != nil {
+			return fmt.Errorf("failed to prepare nonce and GCM: %w", err)
+		}
+
+		payload.Salt = salt
+		s.legacyMessageBody(payload.Body, gcm, nonce, plaintext, padLen)
+
+		return nil
 	}
 
-	salt, err := s.prepareSalt()
+	ikm, err := prepareIKM(keys.publicKey, sharedSecret, authSecret, uaPublicKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to prepare salt: %w", err)
+		return fmt.Errorf("failed to prepare ikm: %w", err)
 	}
 
-	nonce, gcm, err := s.prepareNonceAndGCM(salt, ikm)
+	nonce, gcm, err := prepareNonceAndGCM(salt, ikm)
 	if err != nil {
-		return nil, fmt.Errorf("failed to prepare nonce and GCM: %w", err)
+		return fmt.Errorf("failed to prepare nonce and GCM: %w", err)
 	}
 
-	recordSize := len(plaintext) + 86 + 16 + 1
-	buf := bytes.NewBuffer(make([]byte, 0, recordSize))
-	s.messageHeader(buf, salt, uint32(recordSize))
-	s.messageBody(buf, gcm, nonce, plaintext)
+	s.messageHeader(payload.Body, keys, salt, DefaultRecordSize)
+	s.messageBody(payload.Body, gcm, nonce, plaintext, padLen)
 
-	return buf, nil
+	return nil
 }
 
-// Rotate enables key rotation according to interval.
-func (s *Service) Rotate(interval time.Duration) {
-	go func(s *Service) {
-		ticker := time.NewTicker(interval)
-
-		for range ticker.C {
-			s.mu.Lock()
-			privateKey, _ := ecdh.P256().GenerateKey(rand.Reader)
-			s.privateKey = privateKey
-			s.publicKey = privateKey.PublicKey().Bytes()
-			s.mu.Unlock()
+// keyPair returns the key pair to encrypt the next message with.
+func (s *Service) keyPair() (*keyPair, error) {
+	if s.mode == KeyModeShared {
+		// Rotation swaps the whole snapshot, so encryptors never see a torn pair.
+		return s.keys.Load(), nil
+	}
+
+	if s.pool != nil {
+		return s.pool.Get()
+	}
+
+	return newKeyPair(s.random)
+}
+
+// Close stops background key generation and rotation.
+func (s *Service) Close() {
+	s.once.Do(func() {
+		close(s.done)
+
+		if s.pool != nil {
+			s.pool.Close()
 		}
-	}(s)
+	})
 }
@@ -0,0 +1,38 @@
+package encryption
+
+import "testing"
+
+func BenchmarkEncrypt(b *testing.B) {
+	benchmarks := []struct {
+		name    string
+		options *Options
+	}{
+		{"ephemeral", nil},
+		{"ephemeral without pool", &Options{KeyPoolSize: -1}},
+		{"shared", &Options{KeyMode: KeyModeShared}},
+	}
+
+	_, _, auth, p256dh := newSubscription(b)
+	plaintext := []byte(`{"title":"Hello","body":"When I grow up, I want to be a watermelon"}`)
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			s, err := NewServiceWithOptions(bm.options)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer s.Close()
+
+			b.ReportAllocs()
+
+			for b.Loop() {
+				payload, err := s.EncryptMessage(&Message{Auth: auth, P256DH: p256dh, Plaintext: plaintext})
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				payload.Release()
+			}
+		})
+	}
+}
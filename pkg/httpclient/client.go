@@ -6,11 +6,33 @@ import (
 )
 
 type Headers struct {
-	Authorization string
-	Urgency       string
-	TTL           time.Duration
+	Authorization   string
+	Urgency         string
+	TTL             time.Duration
+	ContentEncoding string // "aes128gcm" if empty.
+	Encryption      string // "Encryption" header, used with "aesgcm" content encoding.
+	CryptoKey       string // "Crypto-Key" header, used with "aesgcm" content encoding.
 }
 
+// contentEncoding returns content encoding of the body.
+func (h *Headers) contentEncoding() string {
+	if h.ContentEncoding == "" {
+		return "aes128gcm"
+	}
+
+	return h.ContentEncoding
+}
+
+// Client delivers push messages. Body belongs to the client, which may keep
+// using it after RequestDelivery returns.
 type Client interface {
 	RequestDelivery(endpoint string, headers *Headers, body *bytes.Buffer) (int, error)
 }
+
+// ReleasingClient is implemented by clients that opt in to pooled bodies. They
+// call release once body is no longer used, which may happen after the delivery
+// request returns, like net/http transports closing request bodies asynchronously.
+// Body is then reused for other messages, while other clients get a copy of it.
+type ReleasingClient interface {
+	RequestDeliveryRelease(endpoint string, headers *Headers, body *bytes.Buffer, release func()) (int, error)
+}
@@ -0,0 +1,179 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ErrEgressUnsupported is returned when egress configuration is applied to a client it can't configure.
+var ErrEgressUnsupported = errors.New("egress configuration is supported only for fasthttp and net/http transports")
+
+// defaultDialTimeout limits connecting to push services and proxies.
+const defaultDialTimeout = 10 * time.Second
+
+// Egress configures outbound connections of clients: an HTTP CONNECT proxy
+// and TLS trust and client certificates for push services and the proxy.
+// Proxy settings of clients, such as HTTPS_PROXY honored by http.DefaultTransport,
+// are replaced only if ProxyURL is set.
+type Egress struct {
+	ProxyURL     string            // [Optional] "http://" or "https://" proxy URL, optionally with credentials.
+	NoProxy      string            // [Optional] Comma-separated hosts, domains and CIDRs connected directly, with NO_PROXY semantics.
+	RootCAs      *x509.CertPool    // [Optional] Trusted CAs of push services, system roots if nil.
+	Certificates []tls.Certificate // [Optional] Client certificates for mutual TLS.
+}
+
+// proxy returns parsed proxy URL, or nil if no proxy is configured.
+func (e *Egress) proxy() (*url.URL, error) {
+	if e.ProxyURL == "" {
+		return nil, nil
+	}
+
+	proxy, err := url.Parse(e.ProxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse proxy URL: %w", err)
+	}
+
+	if proxy.Scheme != "http" && proxy.Scheme != "https" || proxy.Host == "" {
+		return nil, fmt.Errorf("proxy URL must be an absolute http or https URL, got %q", e.ProxyURL)
+	}
+
+	return proxy, nil
+}
+
+// tlsConfig returns a copy of config with egress CAs and certificates.
+func (e *Egress) tlsConfig(config *tls.Config) *tls.Config {
+	if config == nil {
+		config = &tls.Config{MinVersion: tls.VersionTLS12}
+	} else {
+		config = config.Clone()
+	}
+
+	if e.RootCAs != nil {
+		config.RootCAs = e.RootCAs
+	}
+
+	if len(e.Certificates) != 0 {
+		config.Certificates = e.Certificates
+	}
+
+	return config
+}
+
+// WithEgress returns a new client connecting through the proxy and with the TLS
+// settings of egress, using a copy of the underlying fasthttp.Client. Without a
+// proxy URL, the client dials as before. The client f is left unchanged.
+func (f *FastHttpClient) WithEgress(egress *Egress) (ClientV2, error) {
+	proxy, err := egress.proxy()
+	if err != nil {
+		return nil, err
+	}
+
+	client := cloneFastHttpClient(f.client)
+	client.TLSConfig = egress.tlsConfig(client.TLSConfig)
+
+	if proxy != nil {
+		client.DialTimeout = nil
+		client.Dial = (&proxyDialer{
+			proxy:     proxy,
+			noProxy:   parseNoProxy(egress.NoProxy),
+			dialer:    &net.Dialer{},
+			timeout:   defaultDialTimeout,
+			tlsConfig: egress.tlsConfig(nil),
+		}).Dial
+	}
+
+	return &FastHttpClient{client: client}, nil
+}
+
+// WithEgress returns a new client connecting through the proxy and with the TLS
+// settings of egress, using a copy of the underlying http.Client and its
+// transport, which must be an *http.Transport or nil for http.DefaultTransport.
+// Without a proxy URL, the transport Proxy, e.g. http.ProxyFromEnvironment, is
+// kept. The client f is left unchanged.
+func (f *StdHttpClient) WithEgress(egress *Egress) (ClientV2, error) {
+	proxy, err := egress.proxy()
+	if err != nil {
+		return nil, err
+	}
+
+	var transport *http.Transport
+
+	switch t := f.client.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return nil, fmt.Errorf("%w: transport is %T", ErrEgressUnsupported, t)
+	}
+
+	transport.TLSClientConfig = egress.tlsConfig(transport.TLSClientConfig)
+
+	if proxy != nil {
+		noProxy := parseNoProxy(egress.NoProxy)
+
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			if noProxy.Match(canonicalAddr(req.URL)) {
+				return nil, nil
+			}
+
+			return proxy, nil
+		}
+	}
+
+	client := *f.client
+	client.Transport = transport
+
+	return &StdHttpClient{client: &client}, nil
+}
+
+// cloneFastHttpClient returns a new client with configuration of c.
+func cloneFastHttpClient(c *fasthttp.Client) *fasthttp.Client {
+	return &fasthttp.Client{
+		Transport:                     c.Transport,
+		DialTimeout:                   c.DialTimeout,
+		Dial:                          c.Dial,
+		TLSConfig:                     c.TLSConfig,
+		RetryIf:                       c.RetryIf,
+		RetryIfErr:                    c.RetryIfErr,
+		ConfigureClient:               c.ConfigureClient,
+		Name:                          c.Name,
+		MaxConnsPerHost:               c.MaxConnsPerHost,
+		MaxIdleConnDuration:           c.MaxIdleConnDuration,
+		MaxConnDuration:               c.MaxConnDuration,
+		MaxIdemponentCallAttempts:     c.MaxIdemponentCallAttempts,
+		ReadBufferSize:                c.ReadBufferSize,
+		WriteBufferSize:               c.WriteBufferSize,
+		ReadTimeout:                   c.ReadTimeout,
+		WriteTimeout:                  c.WriteTimeout,
+		MaxResponseBodySize:           c.MaxResponseBodySize,
+		MaxConnWaitTimeout:            c.MaxConnWaitTimeout,
+		ConnPoolStrategy:              c.ConnPoolStrategy,
+		NoDefaultUserAgentHeader:      c.NoDefaultUserAgentHeader,
+		DialDualStack:                 c.DialDualStack,
+		DisableHeaderNamesNormalizing: c.DisableHeaderNamesNormalizing,
+		DisablePathNormalizing:        c.DisablePathNormalizing,
+		StreamResponseBody:            c.StreamResponseBody,
+	}
+}
+
+// canonicalAddr returns "host:port" of the URL with the default port of its scheme.
+func canonicalAddr(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+
+	if u.Scheme == "http" {
+		return net.JoinHostPort(u.Hostname(), "80")
+	}
+
+	return net.JoinHostPort(u.Hostname(), "443")
+}
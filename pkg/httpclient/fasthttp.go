@@ -2,7 +2,10 @@ package httpclient
 
 import (
 	"bytes"
-	"strconv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
 	"time"
 
 	"github.com/valyala/fasthttp"
@@ -13,41 +16,104 @@ type FastHttpClient struct {
 }
 
 // FastHttp tell service to use fasthttp.Client. If client is nil, default client will be used.
+// Response bodies longer than the client MaxResponseBodySize are read within the
+// request limit only if it sets StreamResponseBody, like the default client does.
 func FastHttp(client *fasthttp.Client) *FastHttpClient {
 	if client != nil {
 		return &FastHttpClient{client: client}
 	}
 
 	return &FastHttpClient{
-		client: &fasthttp.Client{},
+		client: &fasthttp.Client{
+			MaxResponseBodySize: DefaultMaxResponseBodySize,
+			StreamResponseBody:  true,
+		},
 	}
 }
 
 func (f *FastHttpClient) RequestDelivery(endpoint string, headers *Headers, body *bytes.Buffer) (int, error) {
+	resp, err := f.Do(NewRequest(endpoint, headers, body, nil))
+	if err != nil {
+		return 0, err
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Do sends the request and returns the response with body truncated to the
+// request limit. fasthttp has no per-request hooks, so Response.Timings
+// reports Total only.
+func (f *FastHttpClient) Do(r *Request) (*Response, error) {
+	defer r.release()
+
+	start := time.Now()
+
 	req := fasthttp.AcquireRequest()
 	defer fasthttp.ReleaseRequest(req)
 
 	resp := fasthttp.AcquireResponse()
 	defer fasthttp.ReleaseResponse(resp)
 
-	req.SetRequestURI(endpoint)
+	req.SetRequestURI(r.Endpoint)
+	req.Header.SetMethod(r.method())
 
-	req.Header.SetMethod(fasthttp.MethodPost)
-	req.Header.SetContentType("application/octet-stream")
-	req.Header.SetContentEncoding("aes128gcm")
-	req.Header.SetContentLength(body.Len())
-	req.Header.Set("Authorization", headers.Authorization)
-	req.Header.Set("TTL", strconv.FormatInt(int64(headers.TTL/time.Second), 10))
-
-	if headers.Urgency != "" {
-		req.Header.Set("Urgency", headers.Urgency)
+	for key, values := range r.Header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
 	}
 
-	req.SetBody(body.Bytes())
+	req.Header.SetContentLength(r.Body.Len())
+	req.SetBody(r.Body.Bytes())
 
 	if err := f.client.Do(req, resp); err != nil {
-		return resp.StatusCode(), err
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	header := make(http.Header)
+
+	resp.Header.VisitAll(func(key, value []byte) {
+		header.Add(string(key), string(value))
+	})
+
+	body, err := readBody(resp, r.maxResponseBodySize())
+	if err != nil {
+		return nil, err
 	}
 
-	return resp.StatusCode(), nil
+	return &Response{
+		StatusCode: resp.StatusCode(),
+		Header:     header,
+		Body:       body,
+		Timings:    Timings{Total: time.Since(start)},
+	}, nil
+}
+
+// readBody returns a copy of the response body truncated to limit. Streamed
+// bodies aren't read beyond it, except for draining short ones to reuse the connection.
+func readBody(resp *fasthttp.Response, limit int) ([]byte, error) {
+	stream := resp.BodyStream()
+	if stream == nil {
+		body := resp.Body()
+		if len(body) > limit {
+			body = body[:limit]
+		}
+
+		return bytes.Clone(body), nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(stream, int64(limit)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// Drain the rest of a short body, so the connection can be reused, and drop
+	// the connection otherwise, since fasthttp would reuse it with unread data.
+	if _, err = io.CopyN(io.Discard, stream, maxDrainSize); !errors.Is(err, io.EOF) {
+		resp.SetConnectionClose()
+	}
+
+	_ = resp.CloseBodyStream()
+
+	return body, nil
 }
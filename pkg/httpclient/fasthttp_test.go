@@ -0,0 +1,67 @@
+package httpclient
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFastHttpBoundedBody(t *testing.T) {
+	written := make(chan error, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/large" {
+			w.WriteHeader(http.StatusCreated)
+
+			return
+		}
+
+		w.Header().Set("Content-Length", "16777216")
+		w.WriteHeader(http.StatusBadRequest)
+
+		var err error
+		for i := 0; err == nil && i < 16; i++ {
+			_, err = io.WriteString(w, strings.Repeat("x", 1<<20))
+		}
+
+		written <- err
+	}))
+	defer server.Close()
+
+	client := FastHttp(nil)
+
+	request := NewRequest(server.URL+"/large", &Headers{}, bytes.NewBufferString("message"), nil)
+	request.MaxResponseBodySize = 16
+
+	resp, err := client.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Body) != 16 {
+		t.Fatalf("body of %d octets, want 16", len(resp.Body))
+	}
+
+	select {
+	case err = <-written:
+		if err == nil {
+			t.Fatal("whole response body is read")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("response isn't closed")
+	}
+
+	// The connection with unread body isn't reused.
+	resp, err = client.Do(NewRequest(server.URL, &Headers{}, bytes.NewBufferString("message"), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+}
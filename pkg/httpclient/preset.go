@@ -0,0 +1,105 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// PresetOptions tunes the net/http client preset. Zero fields take defaults
+// suitable for fan-out to push services.
+type PresetOptions struct {
+	Timeout               time.Duration // [Optional] Total request timeout, 30s by default.
+	DialTimeout           time.Duration // [Optional] TCP connect timeout, 5s by default.
+	KeepAlive             time.Duration // [Optional] TCP keep-alive probe interval, 30s by default.
+	TLSHandshakeTimeout   time.Duration // [Optional] TLS handshake timeout, 5s by default.
+	ResponseHeaderTimeout time.Duration // [Optional] Time to wait for response headers after the request is sent, 10s by default.
+	IdleConnTimeout       time.Duration // [Optional] Time to keep idle connections, 90s by default.
+	MaxConnsPerHost       int           // [Optional] Connections per push service, 16 by default.
+	MaxIdleConnsPerHost   int           // [Optional] Idle connections kept per push service, MaxConnsPerHost by default.
+	PingTimeout           time.Duration // [Optional] HTTP/2 health check: ping idle connections after it and drop them if no reply within it, 15s by default.
+	WriteByteTimeout      time.Duration // [Optional] HTTP/2: drop connections on which pending data can't be written for it, 10s by default.
+}
+
+// withDefaults returns options with defaults in place of zero fields.
+func (o PresetOptions) withDefaults() PresetOptions {
+	defaults := []struct {
+		value    *time.Duration
+		fallback time.Duration
+	}{
+		{&o.Timeout, 30 * time.Second},
+		{&o.DialTimeout, 5 * time.Second},
+		{&o.KeepAlive, 30 * time.Second},
+		{&o.TLSHandshakeTimeout, 5 * time.Second},
+		{&o.ResponseHeaderTimeout, 10 * time.Second},
+		{&o.IdleConnTimeout, 90 * time.Second},
+		{&o.PingTimeout, 15 * time.Second},
+		{&o.WriteByteTimeout, 10 * time.Second},
+	}
+
+	for _, d := range defaults {
+		if *d.value <= 0 {
+			*d.value = d.fallback
+		}
+	}
+
+	if o.MaxConnsPerHost <= 0 {
+		o.MaxConnsPerHost = 16
+	}
+
+	if o.MaxIdleConnsPerHost <= 0 {
+		o.MaxIdleConnsPerHost = o.MaxConnsPerHost
+	}
+
+	return o
+}
+
+// NewPushTransport returns a transport tuned for push fan-out: HTTP/2 is
+// negotiated even with the custom dialer and TLS config, so messages to a push
+// service are multiplexed over few connections, and connections are limited,
+// kept alive and health checked per push service. If options are nil, defaults are used.
+func NewPushTransport(options *PresetOptions) *http.Transport {
+	if options == nil {
+		options = &PresetOptions{}
+	}
+
+	o := options.withDefaults()
+
+	dialer := &net.Dialer{
+		Timeout:   o.DialTimeout,
+		KeepAlive: o.KeepAlive,
+	}
+
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       &tls.Config{MinVersion: tls.VersionTLS12},
+		TLSHandshakeTimeout:   o.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: o.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          0, // No global limit, only per push service.
+		MaxConnsPerHost:       o.MaxConnsPerHost,
+		MaxIdleConnsPerHost:   o.MaxIdleConnsPerHost,
+		IdleConnTimeout:       o.IdleConnTimeout,
+		HTTP2: &http.HTTP2Config{
+			SendPingTimeout:  o.PingTimeout,
+			PingTimeout:      o.PingTimeout,
+			WriteByteTimeout: o.WriteByteTimeout,
+		},
+	}
+}
+
+// StdHttpPreset returns a net/http client tuned for push services, with
+// timeouts and the transport of NewPushTransport. If options are nil, defaults are used.
+func StdHttpPreset(options *PresetOptions) *StdHttpClient {
+	if options == nil {
+		options = &PresetOptions{}
+	}
+
+	return StdHttp(&http.Client{
+		Transport: NewPushTransport(options),
+		Timeout:   options.withDefaults().Timeout,
+	})
+}
@@ -0,0 +1,37 @@
+package httpclient
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStdHttpPresetHTTP2(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			w.WriteHeader(http.StatusHTTPVersionNotSupported)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	client := StdHttpPreset(nil)
+
+	transport := client.client.Transport.(*http.Transport)
+	transport.TLSClientConfig.RootCAs = server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+
+	statusCode, err := client.RequestDelivery(server.URL, &Headers{}, bytes.NewBufferString("message"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if statusCode != http.StatusCreated {
+		t.Fatalf("status %d, HTTP/2 isn't negotiated", statusCode)
+	}
+}
@@ -0,0 +1,238 @@
+package httpclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// noProxy matches hosts excluded from proxying by NO_PROXY list.
+type noProxy struct {
+	all      bool
+	prefixes []netip.Prefix
+	hosts    []noProxyHost
+}
+
+// noProxyHost is a domain or IP address entry of NO_PROXY list.
+type noProxyHost struct {
+	host       string
+	port       string
+	subdomains bool // Entry starts with a dot, so only subdomains match.
+}
+
+// parseNoProxy parses comma-separated NO_PROXY list with the semantics of curl
+// and Go: "*" disables proxy, an IP address or CIDR matches addresses, a domain
+// matches itself and subdomains, a domain with a leading dot matches subdomains
+// only, and an entry with a port matches only that port.
+func parseNoProxy(list string) *noProxy {
+	p := &noProxy{}
+
+	for entry := range strings.SplitSeq(list, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+
+		switch {
+		case entry == "":
+			continue
+		case entry == "*":
+			p.all = true
+
+			continue
+		}
+
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			p.prefixes = append(p.prefixes, prefix)
+
+			continue
+		}
+
+		if addr, err := netip.ParseAddr(strings.Trim(entry, "[]")); err == nil {
+			p.prefixes = append(p.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+
+			continue
+		}
+
+		host, port := entry, ""
+		if h, pt, err := net.SplitHostPort(entry); err == nil {
+			host, port = h, pt
+		}
+
+		if addr, err := netip.ParseAddr(host); err == nil {
+			p.hosts = append(p.hosts, noProxyHost{host: addr.String(), port: port})
+
+			continue
+		}
+
+		p.hosts = append(p.hosts, noProxyHost{
+			host:       strings.TrimPrefix(host, "."),
+			port:       port,
+			subdomains: strings.HasPrefix(host, "."),
+		})
+	}
+
+	return p
+}
+
+// Match reports whether the host of "host:port" address must be connected directly.
+// Localhost and loopback addresses are never proxied.
+func (p *noProxy) Match(addr string) bool {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+
+	if ip, err := netip.ParseAddr(host); err == nil && ip.IsLoopback() {
+		return true
+	}
+
+	if p.all {
+		return true
+	}
+
+	if ip, err := netip.ParseAddr(host); err == nil {
+		ip = ip.Unmap()
+
+		for _, prefix := range p.prefixes {
+			if prefix.Contains(ip) {
+				return true
+			}
+		}
+
+		host = ip.String()
+	}
+
+	for _, h := range p.hosts {
+		if h.port != "" && h.port != port {
+			continue
+		}
+
+		if (host == h.host && !h.subdomains) || strings.HasSuffix(host, "."+h.host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// proxyDialer connects to addresses through HTTP CONNECT tunnels of a proxy.
+type proxyDialer struct {
+	proxy     *url.URL
+	noProxy   *noProxy
+	dialer    *net.Dialer
+	timeout   time.Duration
+	tlsConfig *tls.Config // TLS settings of https proxies, with egress CAs and certificates.
+}
+
+// Dial connects to "host:port" address, through the proxy unless it matches NO_PROXY.
+func (d *proxyDialer) Dial(addr string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+
+	if d.noProxy.Match(addr) {
+		conn, err := d.dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect: %w", err)
+		}
+
+		return conn, nil
+	}
+
+	return d.connect(ctx, addr)
+}
+
+// connect opens a tunnel to the address with CONNECT request according to RFC 9110 9.3.6.
+func (d *proxyDialer) connect(ctx context.Context, addr string) (net.Conn, error) {
+	conn, err := d.dialer.DialContext(ctx, "tcp", proxyAddr(d.proxy))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to proxy: %w", err)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if d.proxy.Scheme == "https" {
+		config := d.tlsConfig.Clone()
+		config.ServerName = d.proxy.Hostname()
+
+		tlsConn := tls.Client(conn, config)
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+
+			return nil, fmt.Errorf("failed to handshake with proxy: %w", err)
+		}
+
+		conn = tlsConn
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+
+	if user := d.proxy.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	if err = req.Write(conn); err != nil {
+		_ = conn.Close()
+
+		return nil, fmt.Errorf("failed to send CONNECT request: %w", err)
+	}
+
+	// The proxy sends nothing after its response until the tunnel is used, so
+	// the buffered reader doesn't hold any tunnel data.
+	resp, err := http.ReadResponse(bufio.NewReaderSize(conn, 1024), req)
+	if err != nil {
+		_ = conn.Close()
+
+		return nil, fmt.Errorf("failed to read CONNECT response: %w", err)
+	}
+
+	// A successful response has no body, the connection becomes the tunnel.
+	if resp.StatusCode/100 != 2 {
+		_ = conn.Close()
+
+		return nil, fmt.Errorf("proxy refused CONNECT to %s: %s", addr, resp.Status)
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+
+	return &tunnelConn{Conn: conn}, nil
+}
+
+// tunnelConn is a tunnel through a proxy. It hides TLS connections to https
+// proxies, which fasthttp would otherwise take for TLS connections to push services.
+type tunnelConn struct {
+	net.Conn
+}
+
+// proxyAddr returns "host:port" of the proxy with the default port of its scheme.
+func proxyAddr(proxy *url.URL) string {
+	if proxy.Port() != "" {
+		return proxy.Host
+	}
+
+	if proxy.Scheme == "https" {
+		return net.JoinHostPort(proxy.Hostname(), "443")
+	}
+
+	return net.JoinHostPort(proxy.Hostname(), "80")
+}
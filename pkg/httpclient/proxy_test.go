@@ -0,0 +1,205 @@
+package httpclient
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestNoProxyMatch(t *testing.T) {
+	p := parseNoProxy("example.com, .internal.net, 10.0.0.0/8, 192.168.1.1, push.example.org:8443")
+
+	tests := map[string]bool{
+		"example.com:443":        true,
+		"fcm.example.com:443":    true,
+		"notexample.com:443":     false,
+		"internal.net:443":       false,
+		"api.internal.net:443":   true,
+		"10.1.2.3:443":           true,
+		"11.1.2.3:443":           false,
+		"192.168.1.1:80":         true,
+		"push.example.org:8443":  true,
+		"push.example.org:443":   false,
+		"localhost:8080":         true,
+		"127.0.0.1:443":          true,
+		"[::1]:443":              true,
+		"fcm.googleapis.com:443": false,
+	}
+
+	for addr, want := range tests {
+		if got := p.Match(addr); got != want {
+			t.Errorf("%s: got %v, want %v", addr, got, want)
+		}
+	}
+
+	if !parseNoProxy("*").Match("fcm.googleapis.com:443") {
+		t.Error("* doesn't disable proxy")
+	}
+}
+
+// connectProxy is an HTTP CONNECT proxy counting tunnels, which resolves every host to addr.
+// An https proxy requires a client certificate.
+func connectProxy(t *testing.T, scheme, addr string, tunnels *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	proxy := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect || r.Header.Get("Proxy-Authorization") != "Basic dXNlcjpwYXNz" {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+
+			return
+		}
+
+		target, err := net.Dial("tcp", addr)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+
+			return
+		}
+
+		tunnels.Add(1)
+		w.WriteHeader(http.StatusOK)
+
+		conn, buf, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			_ = target.Close()
+
+			return
+		}
+
+		go func() {
+			_, _ = io.Copy(target, buf)
+			_ = target.Close()
+		}()
+
+		_, _ = io.Copy(conn, target)
+		_ = conn.Close()
+	}))
+
+	if scheme == "https" {
+		proxy.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+		proxy.StartTLS()
+	} else {
+		proxy.Start()
+	}
+
+	return proxy
+}
+
+func TestWithEgressProxy(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	// Loopback is never proxied, so the test server is reached by a name resolved by the proxy.
+	endpoint := "https://example.com"
+
+	clients := map[string]func(egress *Egress) (ClientV2, error){
+		"fasthttp": FastHttp(&fasthttp.Client{}).WithEgress,
+		"std":      StdHttp(nil).WithEgress,
+	}
+
+	for _, scheme := range []string{"http", "https"} {
+		var tunnels atomic.Int32
+
+		proxy := connectProxy(t, scheme, server.Listener.Addr().String(), &tunnels)
+		defer proxy.Close()
+
+		// Test servers share a certificate, which is also presented by the client to the https proxy.
+		egress := &Egress{
+			ProxyURL:     scheme + "://user:pass@" + proxy.Listener.Addr().String(),
+			RootCAs:      server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs,
+			Certificates: server.TLS.Certificates,
+		}
+
+		for name, withEgress := range clients {
+			t.Run(scheme+" "+name, func(t *testing.T) {
+				client, err := withEgress(egress)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				before := tunnels.Load()
+
+				resp, err := client.Do(NewRequest(endpoint, &Headers{}, bytes.NewBufferString("message"), nil))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if resp.StatusCode != http.StatusCreated || tunnels.Load() != before+1 {
+					t.Fatalf("status %d, tunnels %d", resp.StatusCode, tunnels.Load()-before)
+				}
+			})
+		}
+	}
+}
+
+func TestWithEgressCopiesClient(t *testing.T) {
+	egress := &Egress{ProxyURL: "http://proxy.example.com:3128", RootCAs: x509.NewCertPool()}
+
+	fastClient := &fasthttp.Client{}
+	fast := FastHttp(fastClient)
+
+	if _, err := fast.WithEgress(egress); err != nil {
+		t.Fatal(err)
+	}
+
+	if fast.client != fastClient || fastClient.Dial != nil || fastClient.TLSConfig != nil {
+		t.Fatal("fasthttp client is changed")
+	}
+
+	transport := &http.Transport{}
+	stdClient := &http.Client{Transport: transport}
+	std := StdHttp(stdClient)
+
+	if _, err := std.WithEgress(egress); err != nil {
+		t.Fatal(err)
+	}
+
+	// Cloning a transport may set up its HTTP/2 config, but not egress settings.
+	if std.client != stdClient || stdClient.Transport != transport || transport.Proxy != nil ||
+		transport.TLSClientConfig != nil && transport.TLSClientConfig.RootCAs != nil {
+		t.Fatal("net/http client is changed")
+	}
+
+	if _, err := StdHttp(nil).WithEgress(egress); err != nil {
+		t.Fatal(err)
+	}
+
+	if config := http.DefaultTransport.(*http.Transport).TLSClientConfig; config != nil && config.RootCAs != nil {
+		t.Fatal("default transport is changed")
+	}
+}
+
+func TestWithEgressKeepsProxy(t *testing.T) {
+	egress := &Egress{RootCAs: x509.NewCertPool()}
+
+	dial := func(string) (net.Conn, error) { return nil, errors.New("dial") }
+
+	fast, err := FastHttp(&fasthttp.Client{Dial: dial}).WithEgress(egress)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fast.(*FastHttpClient).client.Dial == nil {
+		t.Fatal("fasthttp dialer is replaced without a proxy URL")
+	}
+
+	std, err := StdHttp(nil).WithEgress(egress)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if std.(*StdHttpClient).client.Transport.(*http.Transport).Proxy == nil {
+		t.Fatal("environment proxy is replaced without a proxy URL")
+	}
+}
@@ -0,0 +1,147 @@
+package httpclient
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultMaxResponseBodySize limits response bodies kept by clients, push
+// services reply with short error descriptions at most.
+const DefaultMaxResponseBodySize = 64 << 10
+
+// Request is a push message delivery request.
+type Request struct {
+	Method   string        // [Optional] HTTP method, POST if empty.
+	Endpoint string        // Push resource URL.
+	Header   http.Header   // Request headers, see NewRequest.
+	Body     *bytes.Buffer // Encrypted message, must not be used after Release is called.
+	Release  func()        // [Optional] Called once the client no longer uses Body, even on error.
+
+	MaxResponseBodySize int // [Optional] Limit of Response.Body, DefaultMaxResponseBodySize if zero.
+}
+
+// NewRequest creates a POST request of the push message with web push headers.
+func NewRequest(endpoint string, headers *Headers, body *bytes.Buffer, release func()) *Request {
+	header := make(http.Header, 8)
+
+	header.Set("Content-Type", "application/octet-stream")
+	header.Set("Content-Encoding", headers.contentEncoding())
+	header.Set("Authorization", headers.Authorization)
+	header.Set("TTL", strconv.FormatInt(int64(headers.TTL/time.Second), 10))
+
+	if headers.Urgency != "" {
+		header.Set("Urgency", headers.Urgency)
+	}
+
+	if headers.Encryption != "" {
+		header.Set("Encryption", headers.Encryption)
+	}
+
+	if headers.CryptoKey != "" {
+		header.Set("Crypto-Key", headers.CryptoKey)
+	}
+
+	return &Request{
+		Method:   http.MethodPost,
+		Endpoint: endpoint,
+		Header:   header,
+		Body:     body,
+		Release:  release,
+	}
+}
+
+// method returns request method, POST if empty.
+func (r *Request) method() string {
+	if r.Method == "" {
+		return http.MethodPost
+	}
+
+	return r.Method
+}
+
+// release calls Release if set.
+func (r *Request) release() {
+	if r.Release != nil {
+		r.Release()
+	}
+}
+
+// maxResponseBodySize returns the limit of response body.
+func (r *Request) maxResponseBodySize() int {
+	if r.MaxResponseBodySize <= 0 {
+		return DefaultMaxResponseBodySize
+	}
+
+	return r.MaxResponseBodySize
+}
+
+// headers returns web push headers of the request.
+func (r *Request) headers() *Headers {
+	ttl, _ := strconv.ParseInt(r.Header.Get("TTL"), 10, 64)
+
+	return &Headers{
+		Authorization:   r.Header.Get("Authorization"),
+		Urgency:         r.Header.Get("Urgency"),
+		TTL:             time.Duration(ttl) * time.Second,
+		ContentEncoding: r.Header.Get("Content-Encoding"),
+		Encryption:      r.Header.Get("Encryption"),
+		CryptoKey:       r.Header.Get("Crypto-Key"),
+	}
+}
+
+// Response is a push service response.
+type Response struct {
+	StatusCode int
+	Header     http.Header // Response headers, such as Location and Retry-After. Nil for adapted clients.
+	Body       []byte      // Response body truncated to the request limit. Nil for adapted clients.
+	Timings    Timings     // Duration of the request phases reported by the client.
+}
+
+// ClientV2 delivers push messages and reports push service responses.
+type ClientV2 interface {
+	Do(req *Request) (*Response, error)
+}
+
+// Adapt returns ClientV2 of a Client. It's returned as is if it implements
+// ClientV2, otherwise responses of the adapter have only status codes
+// and total durations.
+func Adapt(client Client) ClientV2 {
+	if v2, ok := client.(ClientV2); ok {
+		return v2
+	}
+
+	return &adapter{client: client}
+}
+
+// adapter is ClientV2 of a Client.
+type adapter struct {
+	client Client
+}
+
+func (a *adapter) Do(req *Request) (*Response, error) {
+	start := time.Now()
+
+	var (
+		statusCode int
+		err        error
+	)
+
+	if client, ok := a.client.(ReleasingClient); ok {
+		statusCode, err = client.RequestDeliveryRelease(req.Endpoint, req.headers(), req.Body, req.release)
+	} else {
+		// The client may keep the body, so it gets a copy and the pooled one is released.
+		body := bytes.NewBuffer(bytes.Clone(req.Body.Bytes()))
+		req.release()
+
+		statusCode, err = a.client.RequestDelivery(req.Endpoint, req.headers(), body)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to request delivery: %w", err)
+	}
+
+	return &Response{StatusCode: statusCode, Timings: Timings{Total: time.Since(start)}}, nil
+}
@@ -0,0 +1,36 @@
+package httpclient
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+// keepingClient is a Client keeping request bodies after delivery.
+type keepingClient struct {
+	bodies []*bytes.Buffer
+}
+
+func (c *keepingClient) RequestDelivery(_ string, _ *Headers, body *bytes.Buffer) (int, error) {
+	c.bodies = append(c.bodies, body)
+
+	return http.StatusCreated, nil
+}
+
+func TestAdaptCopiesBody(t *testing.T) {
+	client := new(keepingClient)
+	body := bytes.NewBufferString("message")
+
+	_, err := Adapt(client).Do(NewRequest("https://push.example.com", &Headers{}, body, func() {
+		// The pooled buffer is reused for another message.
+		body.Reset()
+		body.WriteString("another")
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if kept := client.bodies[0].String(); kept != "message" {
+		t.Fatalf("kept body %q, want %q", kept, "message")
+	}
+}
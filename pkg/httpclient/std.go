@@ -2,13 +2,19 @@ package httpclient
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
-	"strconv"
-	"time"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
 )
 
+// maxDrainSize limits response body discarded to reuse connection, longer bodies
+// are cheaper to drop with the connection.
+const maxDrainSize = 256 << 10
+
 type StdHttpClient struct {
 	client *http.Client
 }
@@ -23,30 +29,110 @@ func StdHttp(client *http.Client) *StdHttpClient {
 	}
 }
 
+// RequestDelivery sends body to endpoint. The transport may read body after
+// RequestDelivery returns, use RequestDeliveryRelease to know when it's done.
 func (f *StdHttpClient) RequestDelivery(endpoint string, headers *Headers, body *bytes.Buffer) (int, error) {
-	req, err := http.NewRequest(http.MethodPost, endpoint, body)
+	return f.RequestDeliveryRelease(endpoint, headers, body, func() {})
+}
+
+// RequestDeliveryRelease sends body to endpoint and calls release once the
+// transport closes the request body, which may happen after it returns.
+func (f *StdHttpClient) RequestDeliveryRelease(endpoint string, headers *Headers, body *bytes.Buffer, release func()) (int, error) {
+	resp, err := f.Do(NewRequest(endpoint, headers, body, release))
+	if err != nil {
+		return 0, err
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Do sends the request and returns the response with body truncated to the
+// request limit. Release of the request is called once the transport closes the
+// request bodies, which may happen after Do returns. Response.Timings reports all
+// phases of the request.
+func (f *StdHttpClient) Do(r *Request) (*Response, error) {
+	// Do holds a reference of the body until the transport is done retrying.
+	pooled := &pooledBody{data: r.Body.Bytes(), release: r.release}
+	pooled.refs.Store(1)
+
+	tracer := newTracer()
+
+	ctx := httptrace.WithClientTrace(context.Background(), tracer.trace())
+
+	req, err := http.NewRequestWithContext(ctx, r.method(), r.Endpoint, nil)
 	if err != nil {
-		return 0, fmt.Errorf("failed to create request: %w", err)
+		pooled.unref()
+
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/octet-stream")
-	req.Header.Set("Content-Encoding", "aes128gcm")
-	req.Header.Set("Content-Length", strconv.Itoa(body.Len()))
-	req.Header.Set("Authorization", headers.Authorization)
-	req.Header.Set("TTL", strconv.FormatInt(int64(headers.TTL/time.Second), 10))
+	req.Body = pooled.open()
+	req.ContentLength = int64(len(pooled.data))
 
-	if headers.Urgency != "" {
-		req.Header.Set("Urgency", headers.Urgency)
+	// The transport rewinds the body to retry a request on another connection.
+	req.GetBody = func() (io.ReadCloser, error) {
+		return pooled.open(), nil
 	}
+	req.Header = r.Header.Clone()
 
 	resp, err := f.client.Do(req)
+	pooled.unref()
+
 	if err != nil {
-		return 0, fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 
 	defer func(body io.ReadCloser) {
+		// Drain the rest of a short body, so the connection can be reused.
+		_, _ = io.CopyN(io.Discard, body, maxDrainSize)
 		_ = body.Close()
 	}(resp.Body)
 
-	return resp.StatusCode, nil
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(r.maxResponseBodySize())))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	return &Response{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+		Timings:    tracer.done(),
+	}, nil
+}
+
+// pooledBody is a request body buffer released once it's no longer referenced
+// by Do and the request bodies reading it.
+type pooledBody struct {
+	data    []byte
+	release func()
+	refs    atomic.Int32
+}
+
+// open returns a new request body of the buffer.
+func (p *pooledBody) open() *releasingBody {
+	p.refs.Add(1)
+
+	return &releasingBody{Reader: bytes.NewReader(p.data), pooled: p}
+}
+
+// unref drops a reference and releases the buffer after the last one.
+func (p *pooledBody) unref() {
+	if p.refs.Add(-1) == 0 {
+		p.release()
+	}
+}
+
+// releasingBody is a request body dropping its buffer reference when the transport closes it.
+type releasingBody struct {
+	*bytes.Reader
+
+	pooled *pooledBody
+	once   sync.Once
+}
+
+func (b *releasingBody) Close() error {
+	b.once.Do(b.pooled.unref)
+
+	return nil
 }
@@ -0,0 +1,46 @@
+package httpclient
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestStdHttpRewindBody(t *testing.T) {
+	var received atomic.Value
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		// 307 makes the client send the body again, rewound with GetBody.
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, "/push", http.StatusTemporaryRedirect)
+
+			return
+		}
+
+		received.Store(string(body))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	var released atomic.Int32
+
+	resp, err := StdHttp(server.Client()).Do(NewRequest(server.URL, &Headers{}, bytes.NewBufferString("message"), func() {
+		released.Add(1)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusCreated || received.Load() != "message" {
+		t.Fatalf("status %d, body %q", resp.StatusCode, received.Load())
+	}
+
+	if released.Load() != 1 {
+		t.Fatalf("body released %d times, want 1", released.Load())
+	}
+}
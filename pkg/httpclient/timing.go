@@ -0,0 +1,92 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings is a breakdown of a request duration. Phases which didn't happen,
+// e.g. DNS lookup and connect on a reused connection, or aren't reported by the
+// client are zero.
+type Timings struct {
+	DNS             time.Duration // Host name resolution.
+	Connect         time.Duration // TCP connect, including all attempted addresses.
+	TLSHandshake    time.Duration // TLS handshake with the push service or the proxy.
+	TimeToFirstByte time.Duration // From the start of the request to the first response byte.
+	Total           time.Duration // From the start of the request to the read response body.
+	ConnReused      bool          // Whether a previously used connection was reused.
+}
+
+// tracer collects Timings of a request with httptrace. Hooks of a dial may be
+// called after the request is done, if it got another idle connection meanwhile.
+type tracer struct {
+	mu sync.Mutex
+
+	start, dnsStart, connectStart, tlsStart time.Time
+	timings                                 Timings
+}
+
+// newTracer starts timing of a request.
+func newTracer() *tracer {
+	return &tracer{start: time.Now()}
+}
+
+// trace returns hooks recording the request phases.
+func (t *tracer) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			t.dnsStart = time.Now()
+			t.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			t.timings.DNS = time.Since(t.dnsStart)
+			t.mu.Unlock()
+		},
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+			t.mu.Unlock()
+		},
+		ConnectDone: func(string, string, error) {
+			t.mu.Lock()
+			t.timings.Connect = time.Since(t.connectStart)
+			t.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			t.tlsStart = time.Now()
+			t.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			t.timings.TLSHandshake = time.Since(t.tlsStart)
+			t.mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.timings.ConnReused = info.Reused
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.timings.TimeToFirstByte = time.Since(t.start)
+			t.mu.Unlock()
+		},
+	}
+}
+
+// done returns the timings with Total measured until now.
+func (t *tracer) done() Timings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.timings.Total = time.Since(t.start)
+
+	return t.timings
+}
@@ -0,0 +1,40 @@
+package httpclient
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStdHttpTimings(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client := StdHttp(server.Client())
+
+	for i, reused := range []bool{false, true} {
+		resp, err := client.Do(NewRequest(server.URL, &Headers{}, bytes.NewBufferString("message"), nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		timings := resp.Timings
+
+		if timings.ConnReused != reused {
+			t.Fatalf("request %d: reused %t, want %t", i, timings.ConnReused, reused)
+		}
+
+		if !reused && (timings.Connect <= 0 || timings.TLSHandshake <= 0) {
+			t.Fatalf("request %d: connect and TLS handshake aren't measured: %+v", i, timings)
+		}
+
+		if timings.TimeToFirstByte < 10*time.Millisecond || timings.Total < timings.TimeToFirstByte {
+			t.Fatalf("request %d: unexpected timings %+v", i, timings)
+		}
+	}
+}
@@ -1,13 +1,11 @@
 package utils
 
 import (
+	"crypto/hkdf"
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
-	"io"
 	"strings"
-
-	"golang.org/x/crypto/hkdf"
 )
 
 func ParseBase64Key(keyString string) ([]byte, error) {
@@ -37,14 +35,32 @@ func ParseBase64Key(keyString string) ([]byte, error) {
 	return data, nil
 }
 
+// HkdfExtractAndExpand returns length octets of HKDF-SHA-256 output of secret, salt and info.
 func HkdfExtractAndExpand(length int, secret, salt, info []byte) ([]byte, error) {
-	buf := make([]byte, length)
+	okm, err := hkdf.Key(sha256.New, secret, salt, string(info), length)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	return okm, nil
+}
 
-	reader := hkdf.New(sha256.New, secret, salt, info)
+// HkdfExtract returns HKDF-SHA-256 pseudorandom key of secret and salt.
+func HkdfExtract(secret, salt []byte) ([]byte, error) {
+	prk, err := hkdf.Extract(sha256.New, secret, salt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract: %w", err)
+	}
 
-	if _, err := io.ReadFull(reader, buf); err != nil {
-		return nil, fmt.Errorf("failed to read: %w", err)
+	return prk, nil
+}
+
+// HkdfExpand returns length octets of HKDF-SHA-256 output of pseudorandom key and info.
+func HkdfExpand(length int, prk, info []byte) ([]byte, error) {
+	okm, err := hkdf.Expand(sha256.New, prk, string(info), length)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand: %w", err)
 	}
 
-	return buf, nil
+	return okm, nil
 }
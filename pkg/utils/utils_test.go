@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// Test case 1 of RFC 5869 Appendix A.
+func TestHkdf(t *testing.T) {
+	secret := bytes.Repeat([]byte{0x0b}, 22)
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+
+	prk, err := HkdfExtract(secret, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := hex.EncodeToString(prk); got != "077709362c2e32df0ddc3f0dc47bba6390b6c73bb50f9c3122ec844ad7c2b3e5" {
+		t.Fatalf("PRK %s", got)
+	}
+
+	okm, err := HkdfExpand(42, prk, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := hex.EncodeToString(okm); got != "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865" {
+		t.Fatalf("OKM %s", got)
+	}
+
+	okm, err = HkdfExtractAndExpand(42, secret, salt, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := hex.EncodeToString(okm); got != "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865" {
+		t.Fatalf("OKM of HkdfExtractAndExpand %s", got)
+	}
+}
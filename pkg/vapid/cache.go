@@ -0,0 +1,101 @@
+package vapid
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTokenRefreshMargin is a reasonable refresh margin for cached tokens.
+const DefaultTokenRefreshMargin = time.Hour
+
+// CacheStats is a snapshot of token cache counters.
+type CacheStats struct {
+	Hits    uint64 // Headers served from the cache.
+	Misses  uint64 // Headers signed because of missing or expiring tokens.
+	Entries int    // Audiences currently cached.
+}
+
+// HitRate returns the share of headers served from the cache, or 0 if there were none.
+func (s CacheStats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+
+	return float64(s.Hits) / float64(total)
+}
+
+// cachedHeader is an authorization header with its refresh time.
+type cachedHeader struct {
+	header    string
+	refreshAt time.Time
+}
+
+// tokenCache keeps authorization headers per audience until they need refresh.
+type tokenCache struct {
+	margin time.Duration
+
+	mu      sync.RWMutex
+	entries map[string]cachedHeader
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// newTokenCache creates a cache refreshing tokens margin before their expiry.
+func newTokenCache(margin time.Duration) *tokenCache {
+	return &tokenCache{
+		margin:  margin,
+		entries: make(map[string]cachedHeader),
+	}
+}
+
+// Get returns a cached header of the audience if it doesn't need refresh yet.
+func (c *tokenCache) Get(audience string, now time.Time) (string, bool) {
+	c.mu.RLock()
+	entry, ok := c.entries[audience]
+	c.mu.RUnlock()
+
+	if !ok || !now.Before(entry.refreshAt) {
+		c.misses.Add(1)
+
+		return "", false
+	}
+
+	c.hits.Add(1)
+
+	return entry.header, true
+}
+
+// Put stores the header of the audience expiring at expiresAt, and drops
+// entries of other audiences that need refresh, so the cache doesn't grow with
+// audiences that are no longer used.
+func (c *tokenCache) Put(audience, header string, now, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, entry := range c.entries {
+		if !now.Before(entry.refreshAt) {
+			delete(c.entries, key)
+		}
+	}
+
+	c.entries[audience] = cachedHeader{
+		header:    header,
+		refreshAt: expiresAt.Add(-c.margin),
+	}
+}
+
+// Stats returns current counters of the cache.
+func (c *tokenCache) Stats() CacheStats {
+	c.mu.RLock()
+	entries := len(c.entries)
+	c.mu.RUnlock()
+
+	return CacheStats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Entries: entries,
+	}
+}
@@ -0,0 +1,93 @@
+package vapid
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHeaderCache(t *testing.T) {
+	s, err := NewServiceWithOptions(&Options{
+		PublicKey:          testPublicKey,
+		PrivateKey:         testPrivateKey,
+		Subject:            testSubject,
+		TokenRefreshMargin: DefaultTokenRefreshMargin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := s.Header(testEndpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+
+	for range 8 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			header, err := s.Header(testEndpoint + "/other")
+			if err != nil || header != first {
+				t.Errorf("header isn't reused for the same audience: %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	other, err := s.Header("https://updates.push.services.mozilla.com/wpush/v2/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if other == first {
+		t.Fatal("header is reused for another audience")
+	}
+
+	stats := s.CacheStats()
+	if stats.Hits != 8 || stats.Misses != 2 || stats.Entries != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	if rate := stats.HitRate(); rate != 0.8 {
+		t.Fatalf("hit rate %v, want 0.8", rate)
+	}
+}
+
+func TestTokenCacheRefresh(t *testing.T) {
+	c := newTokenCache(time.Hour)
+	now := time.Now()
+
+	c.Put("https://a", "a", now, now.Add(DefaultTokenLifetime))
+	c.Put("https://b", "b", now, now.Add(time.Hour))
+
+	if _, ok := c.Get("https://a", now.Add(DefaultTokenLifetime-time.Hour-time.Second)); !ok {
+		t.Fatal("token is refreshed before the margin")
+	}
+
+	if _, ok := c.Get("https://a", now.Add(DefaultTokenLifetime-time.Hour)); ok {
+		t.Fatal("token isn't refreshed within the margin")
+	}
+
+	// Expiring entries of other audiences are dropped on insert.
+	c.Put("https://c", "c", now, now.Add(DefaultTokenLifetime))
+
+	if stats := c.Stats(); stats.Entries != 2 {
+		t.Fatalf("entries %d, want 2", stats.Entries)
+	}
+}
+
+func TestRefreshMarginInvalid(t *testing.T) {
+	if _, err := NewServiceWithOptions(&Options{
+		PublicKey:          testPublicKey,
+		PrivateKey:         testPrivateKey,
+		Subject:            testSubject,
+		TokenRefreshMargin: DefaultTokenLifetime,
+	}); err == nil {
+		t.Fatal("margin not shorter than token lifetime is accepted")
+	}
+}
@@ -1,35 +1,38 @@
 package vapid
 
 import (
+	"crypto"
 	"crypto/ecdh"
 	"crypto/ecdsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"fmt"
-
-	"github.com/gootsolution/pushbell/pkg/utils"
+	"io"
 )
 
-func checkPublicKey(key string) error {
-	publicKey, err := utils.ParseBase64Key(key)
+// publicKeyFromPoint converts an uncompressed P-256 point to a public key.
+func publicKeyFromPoint(point []byte) (*ecdsa.PublicKey, error) {
+	publicECDH, err := ecdh.P256().NewPublicKey(point)
 	if err != nil {
-		return fmt.Errorf("failed to parse public key: %w", err)
+		return nil, fmt.Errorf("failed to create public key: %w", err)
 	}
 
-	_, err = ecdh.P256().NewPublicKey(publicKey)
+	publicPKIX, err := x509.MarshalPKIXPublicKey(publicECDH)
 	if err != nil {
-		return fmt.Errorf("failed to create public key: %w", err)
+		return nil, fmt.Errorf("failed to convert public key: %w", err)
 	}
 
-	return nil
-}
-
-func preparePrivateKey(key string) (*ecdsa.PrivateKey, error) {
-	privateKeyDecoded, err := utils.ParseBase64Key(key)
+	publicECDSA, err := x509.ParsePKIXPublicKey(publicPKIX)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse private key: %w", err)
+		return nil, fmt.Errorf("failed to convert public key: %w", err)
 	}
 
-	privateECDH, err := ecdh.P256().NewPrivateKey(privateKeyDecoded)
+	return publicECDSA.(*ecdsa.PublicKey), nil
+}
+
+// privateKeyFromScalar converts a 32-octet P-256 scalar to a private key.
+func privateKeyFromScalar(scalar []byte) (*ecdsa.PrivateKey, error) {
+	privateECDH, err := ecdh.P256().NewPrivateKey(scalar)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create private key: %w", err)
 	}
@@ -46,3 +49,30 @@ func preparePrivateKey(key string) (*ecdsa.PrivateKey, error) {
 
 	return privateECDSA.(*ecdsa.PrivateKey), nil
 }
+
+// sign returns ES256 signature of signing input according to RFC 7518 3.4:
+// the R and S values as 32 octets big endian unsigned integers, concatenated.
+func sign(random io.Reader, signer crypto.Signer, signingInput []byte) ([]byte, error) {
+	digest := sha256.Sum256(signingInput)
+
+	// In-memory keys skip the ASN.1 roundtrip.
+	if privateKey, ok := signer.(*ecdsa.PrivateKey); ok {
+		r, s, err := ecdsa.Sign(random, privateKey, digest[:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign: %w", err)
+		}
+
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+
+		return signature, nil
+	}
+
+	der, err := signer.Sign(random, digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+
+	return rawSignature(der)
+}
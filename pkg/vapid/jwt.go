@@ -0,0 +1,98 @@
+package vapid
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+)
+
+// jwtHeader is the encoded JOSE header of ES256 tokens according to RFC 8292 2.
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+
+// tokenEncoder encodes VAPID tokens. Claims that don't change between tokens
+// are encoded once, so a token costs a few appends.
+type tokenEncoder struct {
+	tail []byte // "sub" and additional claims with the closing brace.
+}
+
+// newTokenEncoder creates an encoder of tokens with the subject and additional claims.
+func newTokenEncoder(subject string, claims map[string]any) (*tokenEncoder, error) {
+	tail := appendJSONString([]byte(`,"sub":`), subject)
+
+	// Keys are sorted, so tokens are reproducible.
+	for _, key := range slices.Sorted(maps.Keys(claims)) {
+		value, err := json.Marshal(claims[key])
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode claim %q: %w", key, err)
+		}
+
+		tail = append(tail, ',')
+		tail = appendJSONString(tail, key)
+		tail = append(tail, ':')
+		tail = append(tail, value...)
+	}
+
+	return &tokenEncoder{tail: append(tail, '}')}, nil
+}
+
+// AppendSigningInput appends the JWS signing input of the token to dst:
+// base64url(header) || "." || base64url(claims). Issue time is omitted if zero.
+func (e *tokenEncoder) AppendSigningInput(dst []byte, audience string, expiresAt, issuedAt int64) []byte {
+	var buf [128]byte
+
+	claims := append(buf[:0], `{"aud":`...)
+	claims = appendJSONString(claims, audience)
+	claims = append(claims, `,"exp":`...)
+	claims = strconv.AppendInt(claims, expiresAt, 10)
+
+	if issuedAt != 0 {
+		claims = append(claims, `,"iat":`...)
+		claims = strconv.AppendInt(claims, issuedAt, 10)
+	}
+
+	claims = append(claims, e.tail...)
+
+	dst = append(dst, jwtHeader...)
+	dst = append(dst, '.')
+
+	return base64.RawURLEncoding.AppendEncode(dst, claims)
+}
+
+// appendJSONString appends s as a JSON string according to RFC 8259 7.
+func appendJSONString(dst []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+
+	dst = append(dst, '"')
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		switch {
+		case c == '"' || c == '\\':
+			dst = append(dst, '\\', c)
+		case c < 0x20:
+			dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+		case c < utf8.RuneSelf:
+			dst = append(dst, c)
+		default:
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				dst = append(dst, "\ufffd"...)
+			} else {
+				dst = append(dst, s[i:i+size]...)
+			}
+
+			i += size
+
+			continue
+		}
+
+		i++
+	}
+
+	return append(dst, '"')
+}
@@ -0,0 +1,57 @@
+package vapid
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTokenEncoder(t *testing.T) {
+	encoder, err := newTokenEncoder("mailto:\"ops\"@example.com", map[string]any{
+		"tenant": "brand\n",
+		"level":  2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signingInput := encoder.AppendSigningInput(nil, "https://push.example.com", 1700000000, 1699990000)
+
+	_, claims, _ := strings.Cut(string(signingInput), ".")
+
+	decoded, err := base64.RawURLEncoding.DecodeString(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"aud":"https://push.example.com","exp":1700000000,"iat":1699990000,` +
+		`"sub":"mailto:\"ops\"@example.com","level":2,"tenant":"brand\n"}`
+	if string(decoded) != want {
+		t.Fatalf("claims\n%s\nwant\n%s", decoded, want)
+	}
+
+	if !json.Valid(decoded) {
+		t.Fatal("claims aren't valid JSON")
+	}
+}
+
+func TestAppendJSONString(t *testing.T) {
+	for _, s := range []string{"", "plain", `q"b\`, "\x00\x1f", "юникод", "bad\xffutf8"} {
+		var got string
+		if err := json.Unmarshal(appendJSONString(nil, s), &got); err != nil {
+			t.Fatalf("%q: %v", s, err)
+		}
+
+		want := strings.ToValidUTF8(s, "\ufffd")
+		if got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestNewTokenEncoderInvalidClaim(t *testing.T) {
+	if _, err := newTokenEncoder(testSubject, map[string]any{"f": func() {}}); err == nil {
+		t.Fatal("unencodable claim is accepted")
+	}
+}
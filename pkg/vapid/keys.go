@@ -0,0 +1,126 @@
+package vapid
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+)
+
+// KeyPair is an application server P-256 key pair.
+type KeyPair struct {
+	PrivateKey *ecdsa.PrivateKey
+}
+
+// jwk is a JSON Web Key of a P-256 key according to RFC 7518 6.2.
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	D   string `json:"d,omitempty"`
+}
+
+// GenerateKeys generates a new application server key pair.
+func GenerateKeys() (*KeyPair, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	return &KeyPair{PrivateKey: privateKey}, nil
+}
+
+// PublicKeyBytes returns public key as an uncompressed 65-octet P-256 point.
+func (k *KeyPair) PublicKeyBytes() []byte {
+	publicKey, _ := k.PrivateKey.PublicKey.ECDH()
+
+	return publicKey.Bytes()
+}
+
+// PrivateKeyBytes returns private key as a 32-octet scalar.
+func (k *KeyPair) PrivateKeyBytes() []byte {
+	privateKey, _ := k.PrivateKey.ECDH()
+
+	return privateKey.Bytes()
+}
+
+// PublicKeyBase64 returns URL-safe base64 public key, the format of Options.ApplyKeys
+// and of applicationServerKey for PushManager.subscribe in browsers.
+func (k *KeyPair) PublicKeyBase64() string {
+	return base64.RawURLEncoding.EncodeToString(k.PublicKeyBytes())
+}
+
+// PrivateKeyBase64 returns URL-safe base64 private key, the format of Options.ApplyKeys.
+func (k *KeyPair) PrivateKeyBase64() string {
+	return base64.RawURLEncoding.EncodeToString(k.PrivateKeyBytes())
+}
+
+// PrivateKeyPEM returns PEM encoded PKCS #8 private key.
+func (k *KeyPair) PrivateKeyPEM() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// PrivateKeySEC1PEM returns PEM encoded SEC 1 private key, as produced by "openssl ecparam".
+func (k *KeyPair) PrivateKeySEC1PEM() ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(k.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+// PublicKeyPEM returns PEM encoded PKIX public key.
+func (k *KeyPair) PublicKeyPEM() ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(&k.PrivateKey.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// PrivateJWK returns JSON Web Key of the key pair, including the private key.
+func (k *KeyPair) PrivateJWK() ([]byte, error) {
+	key := k.jwk()
+	key.D = base64.RawURLEncoding.EncodeToString(k.PrivateKeyBytes())
+
+	data, err := json.Marshal(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal jwk: %w", err)
+	}
+
+	return data, nil
+}
+
+// PublicJWK returns JSON Web Key of the public key.
+func (k *KeyPair) PublicJWK() ([]byte, error) {
+	data, err := json.Marshal(k.jwk())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal jwk: %w", err)
+	}
+
+	return data, nil
+}
+
+// jwk returns public JSON Web Key of the key pair.
+func (k *KeyPair) jwk() *jwk {
+	point := k.PublicKeyBytes()
+
+	return &jwk{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(point[1:33]),
+		Y:   base64.RawURLEncoding.EncodeToString(point[33:]),
+	}
+}
@@ -0,0 +1,92 @@
+package vapid
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+)
+
+func TestGenerateKeys(t *testing.T) {
+	keys, err := GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Base64 keys are accepted by the service.
+	if _, err = NewService(keys.PublicKeyBase64(), keys.PrivateKeyBase64(), testSubject); err != nil {
+		t.Fatal(err)
+	}
+
+	privatePEM, err := keys.PrivateKeyPEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, _ := pem.Decode(privatePEM)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		t.Fatalf("unexpected PKCS #8 PEM %s", privatePEM)
+	}
+
+	pkcs8, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !keys.PrivateKey.Equal(pkcs8) {
+		t.Fatal("PKCS #8 key mismatch")
+	}
+
+	sec1PEM, err := keys.PrivateKeySEC1PEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, _ = pem.Decode(sec1PEM)
+	if block == nil || block.Type != "EC PRIVATE KEY" {
+		t.Fatalf("unexpected SEC 1 PEM %s", sec1PEM)
+	}
+
+	sec1, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !keys.PrivateKey.Equal(sec1) {
+		t.Fatal("SEC 1 key mismatch")
+	}
+
+	publicPEM, err := keys.PublicKeyPEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, _ = pem.Decode(publicPEM)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		t.Fatalf("unexpected public key PEM %s", publicPEM)
+	}
+
+	pkix, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !keys.PrivateKey.PublicKey.Equal(pkix.(*ecdsa.PublicKey)) {
+		t.Fatal("PKIX key mismatch")
+	}
+
+	data, err := keys.PrivateJWK()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var key map[string]string
+	if err = json.Unmarshal(data, &key); err != nil {
+		t.Fatal(err)
+	}
+
+	if key["kty"] != "EC" || key["crv"] != "P-256" || len(key["x"]) != 43 || len(key["y"]) != 43 || key["d"] != keys.PrivateKeyBase64() {
+		t.Fatalf("unexpected JWK %s", data)
+	}
+}
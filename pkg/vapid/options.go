@@ -0,0 +1,23 @@
+package vapid
+
+import (
+	"crypto"
+	"io"
+	"time"
+)
+
+// Options configures the VAPID service.
+type Options struct {
+	PublicKey          string         // [RFC 8292] Application server public key, see ParsePublicKey for formats. Derived from PrivateKey or Signer if empty.
+	PrivateKey         string         // [RFC 8292] Application server private key, see ParsePrivateKey for formats. Not used with Signer.
+	Subject            string         // [RFC 8292] Either a "mailto:" (email) or a "https:" URI, see ValidateSubject.
+	Random             io.Reader      // [Optional] Entropy source for ECDSA signing, crypto/rand if nil.
+	TokenRefreshMargin time.Duration  // [Optional] If set, cache tokens per audience and sign new ones this long before expiry.
+	TokenLifetime      time.Duration  // [Optional] Time until token expiration, DefaultTokenLifetime if zero.
+	ClockSkew          time.Duration  // [Optional] Allowed clock difference with push services, counted against MaxTokenLifetime.
+	IssuedAt           bool           // [Optional] If set, add "iat" claim backdated by ClockSkew.
+	Claims             map[string]any // [Optional] Additional claims, must not contain "sub", "aud", "exp" or "iat".
+	Scheme             Scheme         // [Optional] Authorization scheme, SchemeVAPID if empty.
+	Signer             crypto.Signer  // [Optional] Signer with P-256 public key, e.g. of a KMS or HSM, used instead of PrivateKey.
+	AllowLocalhost     bool           // [Optional] If set, Subject on localhost is accepted, e.g. for development.
+}
@@ -0,0 +1,229 @@
+package vapid
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/gootsolution/pushbell/pkg/utils"
+)
+
+var (
+	// ErrKeyMismatch is returned when a public key doesn't belong to the private key.
+	ErrKeyMismatch = errors.New("public key doesn't match private key")
+	// ErrUnsupportedKey is returned for keys of unknown format, type or curve.
+	ErrUnsupportedKey = errors.New("unsupported key: expected P-256 key as PEM, DER, JWK or base64")
+)
+
+// ParsePrivateKey parses a P-256 private key. Supported formats are PEM or DER
+// encoded PKCS #8 and SEC 1, JWK with "d" member, and base64 encoded 32-octet scalar.
+func ParsePrivateKey(data []byte) (*ecdsa.PrivateKey, error) {
+	// DER is binary and must not be trimmed.
+	text := bytes.TrimSpace(data)
+
+	switch {
+	case bytes.HasPrefix(text, []byte("-----BEGIN")):
+		block, _ := pem.Decode(text)
+		if block == nil {
+			return nil, fmt.Errorf("%w: malformed PEM", ErrUnsupportedKey)
+		}
+
+		switch block.Type {
+		case "PRIVATE KEY", "EC PRIVATE KEY":
+			return parsePrivateKeyDER(block.Bytes)
+		default:
+			return nil, fmt.Errorf("%w: PEM type %q", ErrUnsupportedKey, block.Type)
+		}
+	case bytes.HasPrefix(text, []byte("{")):
+		key, err := parseJWK(text)
+		if err != nil {
+			return nil, err
+		}
+
+		if key.D == "" {
+			return nil, fmt.Errorf("%w: JWK has no private key", ErrUnsupportedKey)
+		}
+
+		scalar, err := base64.RawURLEncoding.DecodeString(key.D)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode JWK private key: %w", err)
+		}
+
+		privateKey, err := privateKeyFromScalar(scalar)
+		if err != nil {
+			return nil, err
+		}
+
+		// The public part must belong to the private key as well.
+		publicKey, err := key.publicKey()
+		if err != nil {
+			return nil, err
+		}
+
+		if !publicKey.Equal(&privateKey.PublicKey) {
+			return nil, ErrKeyMismatch
+		}
+
+		return privateKey, nil
+	default:
+		scalar, err := utils.ParseBase64Key(string(text))
+		if err != nil {
+			if isDER(data) {
+				return parsePrivateKeyDER(data)
+			}
+
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+
+		return privateKeyFromScalar(scalar)
+	}
+}
+
+// ParsePublicKey parses a P-256 public key. Supported formats are PEM or DER
+// encoded PKIX, JWK, and base64 encoded 65-octet uncompressed point.
+func ParsePublicKey(data []byte) (*ecdsa.PublicKey, error) {
+	// DER is binary and must not be trimmed.
+	text := bytes.TrimSpace(data)
+
+	switch {
+	case bytes.HasPrefix(text, []byte("-----BEGIN")):
+		block, _ := pem.Decode(text)
+		if block == nil {
+			return nil, fmt.Errorf("%w: malformed PEM", ErrUnsupportedKey)
+		}
+
+		if block.Type != "PUBLIC KEY" {
+			return nil, fmt.Errorf("%w: PEM type %q", ErrUnsupportedKey, block.Type)
+		}
+
+		return parsePublicKeyDER(block.Bytes)
+	case bytes.HasPrefix(text, []byte("{")):
+		key, err := parseJWK(text)
+		if err != nil {
+			return nil, err
+		}
+
+		return key.publicKey()
+	default:
+		point, err := utils.ParseBase64Key(string(text))
+		if err != nil {
+			if isDER(data) {
+				return parsePublicKeyDER(data)
+			}
+
+			return nil, fmt.Errorf("failed to parse public key: %w", err)
+		}
+
+		return publicKeyFromPoint(point)
+	}
+}
+
+// LoadKeyPair parses private and public keys in any of supported formats. If
+// publicKey is empty, it's derived from privateKey, otherwise it must match it.
+func LoadKeyPair(privateKey, publicKey []byte) (*KeyPair, error) {
+	privateECDSA, err := ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	if len(bytes.TrimSpace(publicKey)) != 0 {
+		publicECDSA, err := ParsePublicKey(publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key: %w", err)
+		}
+
+		if !publicECDSA.Equal(&privateECDSA.PublicKey) {
+			return nil, ErrKeyMismatch
+		}
+	}
+
+	return &KeyPair{PrivateKey: privateECDSA}, nil
+}
+
+// isDER reports whether data looks like a DER encoded ASN.1 SEQUENCE.
+func isDER(data []byte) bool {
+	return len(data) > 1 && data[0] == 0x30
+}
+
+// parsePrivateKeyDER parses DER encoded PKCS #8 or SEC 1 private key.
+func parsePrivateKeyDER(der []byte) (*ecdsa.PrivateKey, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		privateKey, ok := key.(*ecdsa.PrivateKey)
+		if !ok || privateKey.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%w: PKCS #8 key is not P-256", ErrUnsupportedKey)
+		}
+
+		return privateKey, nil
+	}
+
+	privateKey, err := x509.ParseECPrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("%w: neither PKCS #8 nor SEC 1: %w", ErrUnsupportedKey, err)
+	}
+
+	if privateKey.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("%w: SEC 1 key is not P-256", ErrUnsupportedKey)
+	}
+
+	return privateKey, nil
+}
+
+// parsePublicKeyDER parses DER encoded PKIX public key.
+func parsePublicKeyDER(der []byte) (*ecdsa.PublicKey, error) {
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnsupportedKey, err)
+	}
+
+	publicKey, ok := key.(*ecdsa.PublicKey)
+	if !ok || publicKey.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("%w: PKIX key is not P-256", ErrUnsupportedKey)
+	}
+
+	return publicKey, nil
+}
+
+// parseJWK parses P-256 JSON Web Key.
+func parseJWK(data []byte) (*jwk, error) {
+	var key jwk
+
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("failed to decode JWK: %w", err)
+	}
+
+	if key.Kty != "EC" || key.Crv != "P-256" {
+		return nil, fmt.Errorf("%w: JWK kty %q, crv %q", ErrUnsupportedKey, key.Kty, key.Crv)
+	}
+
+	return &key, nil
+}
+
+// publicKey returns public key of JWK coordinates.
+func (k *jwk) publicKey() (*ecdsa.PublicKey, error) {
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JWK x coordinate: %w", err)
+	}
+
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JWK y coordinate: %w", err)
+	}
+
+	if len(x) != 32 || len(y) != 32 {
+		return nil, fmt.Errorf("%w: JWK coordinates must be 32 octets", ErrUnsupportedKey)
+	}
+
+	point := make([]byte, 0, 65)
+	point = append(point, 0x04)
+	point = append(point, x...)
+	point = append(point, y...)
+
+	return publicKeyFromPoint(point)
+}
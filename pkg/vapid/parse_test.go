@@ -0,0 +1,77 @@
+package vapid
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+)
+
+func TestLoadKeyPair(t *testing.T) {
+	keys, err := GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkcs8PEM, _ := keys.PrivateKeyPEM()
+	sec1PEM, _ := keys.PrivateKeySEC1PEM()
+	publicPEM, _ := keys.PublicKeyPEM()
+	privateJWK, _ := keys.PrivateJWK()
+	publicJWK, _ := keys.PublicJWK()
+	pkcs8DER, _ := x509.MarshalPKCS8PrivateKey(keys.PrivateKey)
+	sec1DER, _ := x509.MarshalECPrivateKey(keys.PrivateKey)
+	publicDER, _ := x509.MarshalPKIXPublicKey(&keys.PrivateKey.PublicKey)
+
+	tests := map[string]struct {
+		privateKey []byte
+		publicKey  []byte
+	}{
+		"base64":          {[]byte(keys.PrivateKeyBase64()), []byte(keys.PublicKeyBase64())},
+		"base64 derived":  {[]byte(keys.PrivateKeyBase64() + "\n"), nil},
+		"PKCS #8 PEM":     {pkcs8PEM, publicPEM},
+		"SEC 1 PEM":       {sec1PEM, nil},
+		"PKCS #8 DER":     {pkcs8DER, publicDER},
+		"SEC 1 DER":       {sec1DER, nil},
+		"JWK":             {privateJWK, publicJWK},
+		"mixed JWK, PEM":  {privateJWK, publicPEM},
+		"mixed DER, b64":  {sec1DER, []byte(keys.PublicKeyBase64())},
+		"JWK derived key": {privateJWK, nil},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := LoadKeyPair(tt.privateKey, tt.publicKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.PrivateKeyBase64() != keys.PrivateKeyBase64() || got.PublicKeyBase64() != keys.PublicKeyBase64() {
+				t.Fatal("loaded key pair differs from the generated one")
+			}
+		})
+	}
+}
+
+func TestLoadKeyPairMismatch(t *testing.T) {
+	keys, _ := GenerateKeys()
+	other, _ := GenerateKeys()
+
+	if _, err := LoadKeyPair([]byte(keys.PrivateKeyBase64()), []byte(other.PublicKeyBase64())); !errors.Is(err, ErrKeyMismatch) {
+		t.Fatalf("got %v, want %v", err, ErrKeyMismatch)
+	}
+}
+
+func TestParsePrivateKeyUnsupported(t *testing.T) {
+	tests := map[string][]byte{
+		"PEM type": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{1}}),
+		"JWK":      []byte(`{"kty":"EC","crv":"P-256","x":"AA","y":"AA"}`),
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParsePrivateKey(data); !errors.Is(err, ErrUnsupportedKey) {
+				t.Fatalf("got %v, want %v", err, ErrUnsupportedKey)
+			}
+		})
+	}
+}
@@ -1,46 +1,148 @@
 package vapid
 
 import (
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
-	"regexp"
 	"time"
+)
+
+const (
+	headerPrefix          = `vapid t=`
+	headerKeySeparator    = `, k=`
+	legacyHeaderPrefix    = `WebPush `
+	legacyCryptoKeyPrefix = `p256ecdsa=`
 
-	"github.com/golang-jwt/jwt/v5"
+	// DefaultTokenLifetime is the time until expiration of signed tokens by default.
+	DefaultTokenLifetime = time.Hour * 12
+
+	// MaxTokenLifetime is the maximum token lifetime allowed by RFC 8292 2.
+	MaxTokenLifetime = time.Hour * 24
 )
 
-const headerTemplate = `vapid t=%s, k=%s`
+var (
+	// ErrTokenLifetime is returned when token lifetime with clock skew exceeds MaxTokenLifetime.
+	ErrTokenLifetime = errors.New("token lifetime with clock skew must not exceed 24 hours")
+	// ErrReservedClaim is returned when extra claims override claims set by the service.
+	ErrReservedClaim = errors.New("claim is reserved")
 
-var errSubjectNotValid = errors.New("subject VAPID should be either a \"mailto:\" (email) or a \"https:\" URI")
+	errRefreshMarginInvalid = errors.New("token refresh margin must be shorter than token lifetime")
+)
+
+// Scheme is an authorization scheme of VAPID headers.
+type Scheme string
+
+const (
+	// SchemeVAPID is the "vapid" scheme defined in RFC 8292 3, with the token and the key in Authorization header.
+	SchemeVAPID Scheme = "vapid"
+	// SchemeWebPush is the "WebPush" scheme of draft-ietf-webpush-vapid-01, with the token in
+	// Authorization header and the key as "p256ecdsa" parameter of Crypto-Key header.
+	SchemeWebPush Scheme = "WebPush"
+)
+
+// reservedClaims are set by the service and can't be overridden by extra claims.
+var reservedClaims = []string{"sub", "aud", "exp", "iat"}
 
 type Service struct {
-	publicKey  string
-	privateKey *ecdsa.PrivateKey
-	subject    string
+	publicKey string
+	cryptoKey string
+	signer    crypto.Signer
+	verifyKey *ecdsa.PublicKey
+	subject   string
+	random    io.Reader
+	cache     *tokenCache
+
+	lifetime  time.Duration
+	clockSkew time.Duration
+	issuedAt  bool
+	encoder   *tokenEncoder
 }
 
+// NewService creates a service signing tokens with the key pair for the subject,
+// see NewServiceWithOptions.
 func NewService(publicKey, privateKey, subject string) (*Service, error) {
-	if !regexp.MustCompile(`^(https:|mailto:)`).MatchString(subject) {
-		return nil, errSubjectNotValid
+	return NewServiceWithOptions(&Options{PublicKey: publicKey, PrivateKey: privateKey, Subject: subject})
+}
+
+// NewServiceWithOptions creates a service signing tokens according to options.
+func NewServiceWithOptions(options *Options) (*Service, error) {
+	if err := ValidateSubject(options.Subject, options.AllowLocalhost); err != nil {
+		return nil, err
+	}
+
+	signer, verifyKey, err := loadSigner(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load keys: %w", err)
+	}
+
+	publicKey, err := publicKeyBase64(verifyKey)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := checkPublicKey(publicKey); err != nil {
-		return nil, fmt.Errorf("failed to validate public key: %w", err)
+	random := options.Random
+	if random == nil {
+		random = rand.Reader
 	}
 
-	privateECDSA, err := preparePrivateKey(privateKey)
+	lifetime := options.TokenLifetime
+	if lifetime <= 0 {
+		lifetime = DefaultTokenLifetime
+	}
+
+	// A push service with a clock behind ours by the skew sees a longer lifetime.
+	if options.ClockSkew < 0 || lifetime+options.ClockSkew > MaxTokenLifetime {
+		return nil, fmt.Errorf("%w: got %s with %s skew", ErrTokenLifetime, lifetime, options.ClockSkew)
+	}
+
+	for _, claim := range reservedClaims {
+		if _, ok := options.Claims[claim]; ok {
+			return nil, fmt.Errorf("%w: %q", ErrReservedClaim, claim)
+		}
+	}
+
+	encoder, err := newTokenEncoder(options.Subject, options.Claims)
 	if err != nil {
-		return nil, fmt.Errorf("failed to prepare private key: %w", err)
+		return nil, err
+	}
+
+	var cryptoKey string
+
+	switch options.Scheme {
+	case "", SchemeVAPID:
+	case SchemeWebPush:
+		cryptoKey = legacyCryptoKeyPrefix + publicKey
+	default:
+		return nil, fmt.Errorf("unsupported authorization scheme %q", options.Scheme)
 	}
 
-	jwt.MarshalSingleStringAsArray = false
+	var cache *tokenCache
+
+	if options.TokenRefreshMargin > 0 {
+		if options.TokenRefreshMargin >= lifetime {
+			return nil, errRefreshMarginInvalid
+		}
+
+		cache = newTokenCache(options.TokenRefreshMargin)
+	}
 
 	return &Service{
-		publicKey:  publicKey,
-		privateKey: privateECDSA,
-		subject:    subject,
+		publicKey: publicKey,
+		cryptoKey: cryptoKey,
+		signer:    signer,
+		verifyKey: verifyKey,
+		subject:   options.Subject,
+		random:    random,
+		cache:     cache,
+		lifetime:  lifetime,
+		clockSkew: options.ClockSkew,
+		issuedAt:  options.IssuedAt,
+		encoder:   encoder,
 	}, nil
 }
 
@@ -50,16 +152,71 @@ func (s *Service) Header(endpoint string) (string, error) {
 		return "", fmt.Errorf("failed to parse endpoint: %w", err)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.RegisteredClaims{
-		Subject:   s.subject,
-		Audience:  jwt.ClaimStrings{fmt.Sprintf("%s://%s", uri.Scheme, uri.Host)},
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 12)),
-	})
+	audience := uri.Scheme + "://" + uri.Host
+	now := time.Now()
 
-	tokenSigned, err := token.SignedString(s.privateKey)
+	if s.cache != nil {
+		if header, ok := s.cache.Get(audience, now); ok {
+			return header, nil
+		}
+	}
+
+	expiresAt := now.Add(s.lifetime)
+
+	// Backdate issue time, so push services with clocks behind ours don't see it in the future.
+	var issuedAt int64
+	if s.issuedAt {
+		issuedAt = now.Add(-s.clockSkew).Unix()
+	}
+
+	prefix := headerPrefix
+	if s.cryptoKey != "" {
+		prefix = legacyHeaderPrefix
+	}
+
+	// The header is built in place: prefix || signing input || "." || signature [|| ", k=" || key].
+	buf := make([]byte, 0, 512)
+	buf = append(buf, prefix...)
+	buf = s.encoder.AppendSigningInput(buf, audience, expiresAt.Unix(), issuedAt)
+
+	signature, err := sign(s.random, s.signer, buf[len(prefix):])
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
 
-	return fmt.Sprintf(headerTemplate, tokenSigned, s.publicKey), nil
+	buf = append(buf, '.')
+	buf = base64.RawURLEncoding.AppendEncode(buf, signature)
+
+	if s.cryptoKey == "" {
+		buf = append(buf, headerKeySeparator...)
+		buf = append(buf, s.publicKey...)
+	}
+
+	header := string(buf)
+
+	if s.cache != nil {
+		s.cache.Put(audience, header, now, expiresAt)
+	}
+
+	return header, nil
+}
+
+// CacheStats returns token cache counters, or zero stats if the cache is disabled.
+func (s *Service) CacheStats() CacheStats {
+	if s.cache == nil {
+		return CacheStats{}
+	}
+
+	return s.cache.Stats()
+}
+
+// CryptoKey returns the "p256ecdsa" parameter of Crypto-Key header for SchemeWebPush,
+// empty string otherwise.
+func (s *Service) CryptoKey() string {
+	return s.cryptoKey
+}
+
+// PublicKey returns URL-safe base64 application server public key.
+func (s *Service) PublicKey() string {
+	return s.publicKey
 }
@@ -0,0 +1,181 @@
+package vapid
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testPublicKey  = "BIRM67G3W1fva-ephDo220BbiaOOy-SBk2uzHsmlqMXp_OmkKxYW96cOK5EWnKdkLg2i7N4FYfuxIwm7JWThVSY"
+	testPrivateKey = "QxfAyO5dMMrSvDT2_xHxW5aktYPWGE_hT42RKlHilpQ"
+	testSubject    = "mailto:push@example.com"
+	testEndpoint   = "https://fcm.googleapis.com/fcm/send/e2CN0r8ft38"
+)
+
+// countingReader counts octets read from crypto/rand.
+type countingReader struct {
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	r.n += len(p)
+
+	return rand.Read(p)
+}
+
+// parseToken verifies ES256 signature and header of the token and returns its claims.
+func parseToken(t *testing.T, token string, publicKey *ecdsa.PublicKey) map[string]any {
+	t.Helper()
+
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 || strings.Count(token, ".") != 2 {
+		t.Fatalf("malformed token %q", token)
+	}
+
+	signingInput, signature := token[:i], token[i+1:]
+	header, claims, _ := strings.Cut(signingInput, ".")
+
+	if decoded, _ := base64.RawURLEncoding.DecodeString(header); string(decoded) != `{"typ":"JWT","alg":"ES256"}` {
+		t.Fatalf("unexpected JOSE header %s", decoded)
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || len(raw) != 64 {
+		t.Fatalf("malformed signature %q", signature)
+	}
+
+	digest := sha256.Sum256([]byte(signingInput))
+	if !ecdsa.Verify(publicKey, digest[:], new(big.Int).SetBytes(raw[:32]), new(big.Int).SetBytes(raw[32:])) {
+		t.Fatal("invalid signature")
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result map[string]any
+	if err = json.Unmarshal(decoded, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+func TestHeader(t *testing.T) {
+	random := new(countingReader)
+
+	s, err := NewServiceWithOptions(&Options{
+		PublicKey:  testPublicKey,
+		PrivateKey: testPrivateKey,
+		Subject:    testSubject,
+		Random:     random,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header, err := s.Header(testEndpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenString, publicKey, ok := strings.Cut(strings.TrimPrefix(header, "vapid t="), ", k=")
+	if !ok || publicKey != testPublicKey {
+		t.Fatalf("malformed header %q", header)
+	}
+
+	claims := parseToken(t, tokenString, s.verifyKey)
+
+	if claims["aud"] != "https://fcm.googleapis.com" || claims["sub"] != testSubject {
+		t.Fatalf("unexpected claims %v", claims)
+	}
+
+	if random.n == 0 {
+		t.Fatal("signature doesn't use the entropy source")
+	}
+}
+
+func BenchmarkHeader(b *testing.B) {
+	s, err := NewServiceWithOptions(&Options{
+		PublicKey:  testPublicKey,
+		PrivateKey: testPrivateKey,
+		Subject:    testSubject,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		if _, err = s.Header(testEndpoint); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestHeaderClaims(t *testing.T) {
+	s, err := NewServiceWithOptions(&Options{
+		PublicKey:     testPublicKey,
+		PrivateKey:    testPrivateKey,
+		Subject:       testSubject,
+		TokenLifetime: time.Hour,
+		ClockSkew:     time.Minute,
+		IssuedAt:      true,
+		Claims:        map[string]any{"tenant": "brand"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header, err := s.Header(testEndpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenString, _, _ := strings.Cut(strings.TrimPrefix(header, "vapid t="), ", k=")
+
+	claims := parseToken(t, tokenString, s.verifyKey)
+
+	exp, _ := claims["exp"].(float64)
+	iat, _ := claims["iat"].(float64)
+
+	if lifetime := time.Duration(exp-iat) * time.Second; lifetime != time.Hour+time.Minute {
+		t.Fatalf("exp - iat = %s, want 1h1m", lifetime)
+	}
+
+	if claims["tenant"] != "brand" {
+		t.Fatalf("extra claim is missing: %v", claims)
+	}
+}
+
+func TestNewServiceTokenOptions(t *testing.T) {
+	tests := map[string]struct {
+		options *Options
+		err     error
+	}{
+		"lifetime": {&Options{TokenLifetime: MaxTokenLifetime + time.Second}, ErrTokenLifetime},
+		"skew":     {&Options{ClockSkew: time.Hour * 13}, ErrTokenLifetime},
+		"claim":    {&Options{Claims: map[string]any{"exp": 0}}, ErrReservedClaim},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.options.PrivateKey = testPrivateKey
+			tt.options.Subject = testSubject
+
+			if _, err := NewServiceWithOptions(tt.options); !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package vapid
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// ErrInvalidSignature is returned when a signer returns a malformed ECDSA signature.
+var ErrInvalidSignature = errors.New("invalid ECDSA signature")
+
+// SoftwareSigner is a crypto.Signer holding the private key in memory. It's a
+// stand-in for KMS or HSM signers in tests, with the same ASN.1 signature output.
+type SoftwareSigner struct {
+	privateKey *ecdsa.PrivateKey
+}
+
+// NewSoftwareSigner returns a signer of the private key.
+func NewSoftwareSigner(privateKey *ecdsa.PrivateKey) *SoftwareSigner {
+	return &SoftwareSigner{privateKey: privateKey}
+}
+
+// Public returns the public key of the signer.
+func (s *SoftwareSigner) Public() crypto.PublicKey {
+	return &s.privateKey.PublicKey
+}
+
+// Sign returns ASN.1 DER encoded ECDSA signature of the digest.
+func (s *SoftwareSigner) Sign(random io.Reader, digest []byte, _ crypto.SignerOpts) ([]byte, error) {
+	signature, err := ecdsa.SignASN1(random, s.privateKey, digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+
+	return signature, nil
+}
+
+// signerPublicKey returns the public key of the signer, which must be a P-256 key.
+func signerPublicKey(signer crypto.Signer) (*ecdsa.PublicKey, error) {
+	publicKey, ok := signer.Public().(*ecdsa.PublicKey)
+	if !ok || publicKey.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("%w: signer public key is %T", ErrUnsupportedKey, signer.Public())
+	}
+
+	return publicKey, nil
+}
+
+// rawSignature converts ASN.1 DER encoded ECDSA signature, as returned by
+// crypto.Signer, to R || S of 32 octets each according to RFC 7518 3.4.
+func rawSignature(der []byte) ([]byte, error) {
+	var sig struct {
+		R, S *big.Int
+	}
+
+	rest, err := asn1.Unmarshal(der, &sig)
+	if err != nil || len(rest) != 0 {
+		return nil, fmt.Errorf("%w: malformed ASN.1", ErrInvalidSignature)
+	}
+
+	if sig.R.Sign() <= 0 || sig.S.Sign() <= 0 || sig.R.BitLen() > 256 || sig.S.BitLen() > 256 {
+		return nil, fmt.Errorf("%w: R or S out of range", ErrInvalidSignature)
+	}
+
+	signature := make([]byte, 64)
+	sig.R.FillBytes(signature[:32])
+	sig.S.FillBytes(signature[32:])
+
+	return signature, nil
+}
+
+// publicKeyBase64 returns URL-safe base64 uncompressed point of the public key.
+func publicKeyBase64(publicKey *ecdsa.PublicKey) (string, error) {
+	publicECDH, err := publicKey.ECDH()
+	if err != nil {
+		return "", fmt.Errorf("failed to convert public key: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(publicECDH.Bytes()), nil
+}
+
+// loadSigner returns the signer and its public key of the options, either
+// Options.Signer or one of Options.PrivateKey.
+func loadSigner(options *Options) (crypto.Signer, *ecdsa.PublicKey, error) {
+	if options.Signer == nil {
+		keys, err := LoadKeyPair([]byte(options.PrivateKey), []byte(options.PublicKey))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return keys.PrivateKey, &keys.PrivateKey.PublicKey, nil
+	}
+
+	if len(bytes.TrimSpace([]byte(options.PrivateKey))) != 0 {
+		return nil, nil, errors.New("private key and signer are mutually exclusive")
+	}
+
+	publicKey, err := signerPublicKey(options.Signer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(bytes.TrimSpace([]byte(options.PublicKey))) != 0 {
+		parsed, err := ParsePublicKey([]byte(options.PublicKey))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse public key: %w", err)
+		}
+
+		if !parsed.Equal(publicKey) {
+			return nil, nil, ErrKeyMismatch
+		}
+	}
+
+	return options.Signer, publicKey, nil
+}
@@ -0,0 +1,80 @@
+package vapid
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHeaderSigner(t *testing.T) {
+	keys, err := LoadKeyPair([]byte(testPrivateKey), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewServiceWithOptions(&Options{
+		Signer:  NewSoftwareSigner(keys.PrivateKey),
+		Subject: testSubject,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.PublicKey() != testPublicKey {
+		t.Fatalf("public key %s, want %s", s.PublicKey(), testPublicKey)
+	}
+
+	header, err := s.Header(testEndpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenString, _, _ := strings.Cut(strings.TrimPrefix(header, "vapid t="), ", k=")
+
+	parseToken(t, tokenString, &keys.PrivateKey.PublicKey)
+}
+
+func TestNewServiceSigner(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	keys, _ := GenerateKeys()
+
+	tests := map[string]struct {
+		options *Options
+		err     error
+	}{
+		"not P-256": {&Options{Signer: edKey}, ErrUnsupportedKey},
+		"mismatch":  {&Options{Signer: keys.PrivateKey, PublicKey: testPublicKey}, ErrKeyMismatch},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.options.Subject = testSubject
+
+			if _, err := NewServiceWithOptions(tt.options); !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRawSignature(t *testing.T) {
+	keys, _ := GenerateKeys()
+	digest := make([]byte, 32)
+
+	der, err := NewSoftwareSigner(keys.PrivateKey).Sign(rand.Reader, digest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if raw, err := rawSignature(der); err != nil || len(raw) != 64 {
+		t.Fatalf("got %x, %v", raw, err)
+	}
+
+	for _, malformed := range [][]byte{nil, der[:len(der)-1], append(der, 0)} {
+		if _, err = rawSignature(malformed); !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("got %v, want %v", err, ErrInvalidSignature)
+		}
+	}
+}
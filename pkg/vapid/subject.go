@@ -0,0 +1,122 @@
+package vapid
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrInvalidSubject is returned for subjects that are neither a "mailto:" email address nor a "https:" URL.
+	// All subject errors wrap it.
+	ErrInvalidSubject = errors.New("subject must be a \"mailto:\" email address or a \"https:\" URL")
+	// ErrSubjectScheme is returned for subjects with a scheme other than "mailto" and "https".
+	ErrSubjectScheme = errors.New("unsupported subject scheme")
+	// ErrSubjectAddress is returned for "mailto:" subjects without a valid email address.
+	ErrSubjectAddress = errors.New("invalid email address")
+	// ErrSubjectHost is returned for "https:" subjects that aren't absolute URLs with a host.
+	ErrSubjectHost = errors.New("URL must be absolute with a host")
+	// ErrSubjectLocalhost is returned for subjects on localhost or loopback addresses, which push services reject.
+	ErrSubjectLocalhost = errors.New("localhost is not allowed")
+)
+
+// addrSpecRegexp matches a dot-atom addr-spec of RFC 5322 3.4.1, as allowed in
+// "mailto:" URIs by RFC 6068 2.
+var addrSpecRegexp = regexp.MustCompile(
+	"^[A-Za-z0-9!#$%&'*+/=?^_`{|}~-]+(?:\\.[A-Za-z0-9!#$%&'*+/=?^_`{|}~-]+)*" +
+		`@[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?)*$`,
+)
+
+// SubjectError describes an invalid subject. It wraps ErrInvalidSubject and the reason.
+type SubjectError struct {
+	Subject string
+	Reason  error
+}
+
+func (e *SubjectError) Error() string {
+	return fmt.Sprintf("invalid VAPID subject %q: %v", e.Subject, e.Reason)
+}
+
+func (e *SubjectError) Unwrap() []error {
+	return []error{ErrInvalidSubject, e.Reason}
+}
+
+// ValidateSubject checks that the subject is a "mailto:" URI with email addresses
+// according to RFC 6068, or an absolute "https:" URL with a host, according to
+// RFC 8292 2.1. Subjects on localhost are rejected unless allowLocalhost is set.
+func ValidateSubject(subject string, allowLocalhost bool) error {
+	scheme, rest, _ := strings.Cut(subject, ":")
+
+	var err error
+
+	switch strings.ToLower(scheme) {
+	case "mailto":
+		err = validateMailto(rest, allowLocalhost)
+	case "https":
+		err = validateHTTPS(subject, allowLocalhost)
+	default:
+		err = ErrSubjectScheme
+	}
+
+	if err != nil {
+		return &SubjectError{Subject: subject, Reason: err}
+	}
+
+	return nil
+}
+
+// validateMailto checks addresses of "mailto:" URI: to ["?" hfields].
+func validateMailto(uri string, allowLocalhost bool) error {
+	to, _, _ := strings.Cut(uri, "?")
+	if to == "" {
+		return fmt.Errorf("%w: no address", ErrSubjectAddress)
+	}
+
+	for address := range strings.SplitSeq(to, ",") {
+		decoded, err := url.PathUnescape(address)
+		if err != nil || !addrSpecRegexp.MatchString(decoded) {
+			return fmt.Errorf("%w: %q", ErrSubjectAddress, address)
+		}
+
+		_, domain, _ := strings.Cut(decoded, "@")
+		if !allowLocalhost && isLocalhost(domain) {
+			return ErrSubjectLocalhost
+		}
+	}
+
+	return nil
+}
+
+// validateHTTPS checks that "https:" URI is an absolute URL with a host.
+func validateHTTPS(uri string, allowLocalhost bool) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSubjectHost, err)
+	}
+
+	if u.Opaque != "" || u.Hostname() == "" {
+		return ErrSubjectHost
+	}
+
+	if !allowLocalhost && isLocalhost(u.Hostname()) {
+		return ErrSubjectLocalhost
+	}
+
+	return nil
+}
+
+// isLocalhost reports whether host is a localhost name or a loopback or unspecified address.
+func isLocalhost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+
+	return ip != nil && (ip.IsLoopback() || ip.IsUnspecified())
+}
@@ -0,0 +1,51 @@
+package vapid
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateSubject(t *testing.T) {
+	tests := map[string]error{
+		"mailto:push@example.com":                nil,
+		"MAILTO:push@example.com":                nil,
+		"mailto:a@example.com,b%2Bc@example.org": nil,
+		"mailto:push@example.com?subject=VAPID":  nil,
+		"https://example.com":                    nil,
+		"https://example.com/contact?x=1":        nil,
+		"mailto:":                                ErrSubjectAddress,
+		"mailto:?subject=x":                      ErrSubjectAddress,
+		"mailto:push":                            ErrSubjectAddress,
+		"mailto:push@":                           ErrSubjectAddress,
+		"mailto:push@-example.com":               ErrSubjectAddress,
+		"mailto:push@localhost":                  ErrSubjectLocalhost,
+		"https:":                                 ErrSubjectHost,
+		"https:example.com":                      ErrSubjectHost,
+		"https:///path":                          ErrSubjectHost,
+		"https://localhost:8080":                 ErrSubjectLocalhost,
+		"https://127.0.0.1":                      ErrSubjectLocalhost,
+		"https://[::1]/":                         ErrSubjectLocalhost,
+		"https://app.localhost":                  ErrSubjectLocalhost,
+		"http://example.com":                     ErrSubjectScheme,
+		"push@example.com":                       ErrSubjectScheme,
+	}
+
+	for subject, want := range tests {
+		err := ValidateSubject(subject, false)
+		if !errors.Is(err, want) {
+			t.Errorf("%q: got %v, want %v", subject, err, want)
+		}
+
+		if want != nil && !errors.Is(err, ErrInvalidSubject) {
+			t.Errorf("%q: %v doesn't wrap ErrInvalidSubject", subject, err)
+		}
+	}
+}
+
+func TestValidateSubjectAllowLocalhost(t *testing.T) {
+	for _, subject := range []string{"mailto:dev@localhost", "https://localhost:8080"} {
+		if err := ValidateSubject(subject, true); err != nil {
+			t.Errorf("%q: %v", subject, err)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package vapid
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrMalformedHeader is returned when Authorization header isn't a "vapid" scheme with "t" and "k" parameters.
+	ErrMalformedHeader = errors.New("malformed VAPID authorization header")
+	// ErrMalformedToken is returned when the token isn't a valid JWS compact serialization.
+	ErrMalformedToken = errors.New("malformed VAPID token")
+	// ErrUnsupportedAlgorithm is returned when the token isn't signed with ES256.
+	ErrUnsupportedAlgorithm = errors.New("unsupported token algorithm, expected ES256")
+	// ErrSignatureMismatch is returned when the token signature doesn't match the key.
+	ErrSignatureMismatch = errors.New("token signature doesn't match the key")
+	// ErrAudienceMismatch is returned when "aud" claim isn't the origin of the push resource.
+	ErrAudienceMismatch = errors.New("token audience doesn't match the origin")
+	// ErrTokenExpired is returned when "exp" claim is in the past.
+	ErrTokenExpired = errors.New("token has expired")
+	// ErrExpiryTooFar is returned when "exp" claim is more than MaxTokenLifetime in the future.
+	ErrExpiryTooFar = errors.New("token expiration is more than 24 hours in the future")
+	// ErrTokenIssuedInFuture is returned when "iat" claim is in the future.
+	ErrTokenIssuedInFuture = errors.New("token is issued in the future")
+)
+
+// Claims are verified claims of a VAPID token.
+type Claims struct {
+	Audience  string         // Origin of the push resource.
+	Subject   string         // Contact of the application server, empty if absent.
+	ExpiresAt time.Time      // Token expiration time.
+	IssuedAt  time.Time      // Token issue time, zero if absent.
+	PublicKey string         // URL-safe base64 application server public key from the header.
+	Extra     map[string]any // Other claims.
+}
+
+// VerifierOptions configures the verifier.
+type VerifierOptions struct {
+	Leeway         time.Duration    // [Optional] Allowed clock difference with application servers.
+	RequireSubject bool             // [Optional] If set, tokens without "sub" claim are rejected.
+	AllowLocalhost bool             // [Optional] If set, subjects on localhost are accepted, see ValidateSubject.
+	Now            func() time.Time // [Optional] Current time source, time.Now if nil.
+}
+
+// Verifier validates VAPID authorization headers on the push service side.
+type Verifier struct {
+	leeway         time.Duration
+	requireSubject bool
+	allowLocalhost bool
+	now            func() time.Time
+}
+
+// NewVerifier creates a verifier. If options are nil, defaults are used.
+func NewVerifier(options *VerifierOptions) *Verifier {
+	if options == nil {
+		options = &VerifierOptions{}
+	}
+
+	now := options.Now
+	if now == nil {
+		now = time.Now
+	}
+
+	return &Verifier{
+		leeway:         options.Leeway,
+		requireSubject: options.RequireSubject,
+		allowLocalhost: options.AllowLocalhost,
+		now:            now,
+	}
+}
+
+// Verify validates the Authorization header of a request to a push resource of
+// the origin, "https://push.example.com" for instance, according to RFC 8292:
+// the ES256 signature against "k" parameter, "aud", "exp" and "sub" claims.
+func (v *Verifier) Verify(header, origin string) (*Claims, error) {
+	token, publicKey, err := parseHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	point, err := base64.RawURLEncoding.DecodeString(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: key isn't URL-safe base64", ErrMalformedHeader)
+	}
+
+	key, err := publicKeyFromPoint(point)
+	if err != nil {
+		return nil, fmt.Errorf("%w: key isn't a P-256 point", ErrMalformedHeader)
+	}
+
+	payload, err := verifyToken(token, key)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := parseClaims(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	claims.PublicKey = publicKey
+
+	if err = v.validate(claims, origin); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// validate checks claims against the origin and the current time.
+func (v *Verifier) validate(claims *Claims, origin string) error {
+	if !strings.EqualFold(claims.Audience, strings.TrimSuffix(origin, "/")) {
+		return fmt.Errorf("%w: %q, want %q", ErrAudienceMismatch, claims.Audience, origin)
+	}
+
+	now := v.now()
+
+	if !now.Add(-v.leeway).Before(claims.ExpiresAt) {
+		return fmt.Errorf("%w: at %s", ErrTokenExpired, claims.ExpiresAt)
+	}
+
+	if claims.ExpiresAt.After(now.Add(MaxTokenLifetime + v.leeway)) {
+		return fmt.Errorf("%w: at %s", ErrExpiryTooFar, claims.ExpiresAt)
+	}
+
+	if !claims.IssuedAt.IsZero() && claims.IssuedAt.After(now.Add(v.leeway)) {
+		return fmt.Errorf("%w: at %s", ErrTokenIssuedInFuture, claims.IssuedAt)
+	}
+
+	if claims.Subject == "" {
+		if v.requireSubject {
+			return fmt.Errorf("%w: missing", ErrInvalidSubject)
+		}
+
+		return nil
+	}
+
+	if err := ValidateSubject(claims.Subject, v.allowLocalhost); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// parseHeader returns "t" and "k" parameters of "vapid" Authorization header according to RFC 8292 3.
+func parseHeader(header string) (string, string, error) {
+	scheme, params, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || !strings.EqualFold(scheme, string(SchemeVAPID)) {
+		return "", "", fmt.Errorf("%w: expected vapid scheme", ErrMalformedHeader)
+	}
+
+	var token, key string
+
+	for param := range strings.SplitSeq(params, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok {
+			return "", "", fmt.Errorf("%w: parameter without value", ErrMalformedHeader)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "t":
+			token = strings.TrimSpace(value)
+		case "k":
+			key = strings.TrimSpace(value)
+		}
+	}
+
+	if token == "" || key == "" {
+		return "", "", fmt.Errorf("%w: missing t or k parameter", ErrMalformedHeader)
+	}
+
+	return token, key, nil
+}
+
+// verifyToken checks the JOSE header and ES256 signature of the token and returns its decoded payload.
+func verifyToken(token string, key *ecdsa.PublicKey) ([]byte, error) {
+	header, rest, ok := strings.Cut(token, ".")
+	payload, signature, ok2 := strings.Cut(rest, ".")
+
+	if !ok || !ok2 || strings.Contains(signature, ".") {
+		return nil, fmt.Errorf("%w: expected three parts", ErrMalformedToken)
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: header isn't URL-safe base64", ErrMalformedToken)
+	}
+
+	var jose struct {
+		Alg string `json:"alg"`
+		Typ string `json:"typ"`
+	}
+
+	if err = json.Unmarshal(headerJSON, &jose); err != nil {
+		return nil, fmt.Errorf("%w: header isn't a JSON object", ErrMalformedToken)
+	}
+
+	if jose.Alg != "ES256" {
+		return nil, fmt.Errorf("%w: got %q", ErrUnsupportedAlgorithm, jose.Alg)
+	}
+
+	if jose.Typ != "" && !strings.EqualFold(jose.Typ, "JWT") {
+		return nil, fmt.Errorf("%w: unexpected type %q", ErrMalformedToken, jose.Typ)
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || len(raw) != 64 {
+		return nil, fmt.Errorf("%w: signature must be 64 octets of URL-safe base64", ErrMalformedToken)
+	}
+
+	digest := sha256.Sum256([]byte(token[:len(header)+1+len(payload)]))
+	r, s := new(big.Int).SetBytes(raw[:32]), new(big.Int).SetBytes(raw[32:])
+
+	if !ecdsa.Verify(key, digest[:], r, s) {
+		return nil, ErrSignatureMismatch
+	}
+
+	claims, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: payload isn't URL-safe base64", ErrMalformedToken)
+	}
+
+	return claims, nil
+}
+
+// parseClaims decodes token claims.
+func parseClaims(payload []byte) (*Claims, error) {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+
+	var raw map[string]any
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("%w: claims aren't a JSON object", ErrMalformedToken)
+	}
+
+	claims := &Claims{}
+
+	audience, ok := raw["aud"].(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: aud must be a string", ErrMalformedToken)
+	}
+
+	claims.Audience = audience
+
+	if sub, ok := raw["sub"]; ok {
+		if claims.Subject, ok = sub.(string); !ok {
+			return nil, fmt.Errorf("%w: sub must be a string", ErrInvalidSubject)
+		}
+	}
+
+	exp, err := numericDate(raw["exp"])
+	if err != nil {
+		return nil, fmt.Errorf("%w: exp %w", ErrMalformedToken, err)
+	}
+
+	claims.ExpiresAt = exp
+
+	if iat, ok := raw["iat"]; ok {
+		if claims.IssuedAt, err = numericDate(iat); err != nil {
+			return nil, fmt.Errorf("%w: iat %w", ErrMalformedToken, err)
+		}
+	}
+
+	for _, claim := range reservedClaims {
+		delete(raw, claim)
+	}
+
+	if len(raw) != 0 {
+		claims.Extra = raw
+	}
+
+	return claims, nil
+}
+
+// maxNumericDate is the largest accepted NumericDate, far beyond any valid expiration.
+const maxNumericDate = 1 << 40
+
+// numericDate converts JWT NumericDate according to RFC 7519 2 to time.
+func numericDate(value any) (time.Time, error) {
+	number, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, errors.New("must be a number")
+	}
+
+	seconds, err := number.Float64()
+	if err != nil || seconds < 0 || seconds > maxNumericDate {
+		return time.Time{}, errors.New("must be a number of seconds since epoch")
+	}
+
+	whole, fraction := math.Modf(seconds)
+
+	return time.Unix(int64(whole), int64(fraction*float64(time.Second))), nil
+}
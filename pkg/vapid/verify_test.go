@@ -0,0 +1,112 @@
+package vapid
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testOrigin = "https://fcm.googleapis.com"
+
+func testHeader(t *testing.T, options *Options) string {
+	t.Helper()
+
+	options.PrivateKey = testPrivateKey
+
+	if options.Subject == "" {
+		options.Subject = testSubject
+	}
+
+	s, err := NewServiceWithOptions(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header, err := s.Header(testEndpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return header
+}
+
+func TestVerify(t *testing.T) {
+	header := testHeader(t, &Options{IssuedAt: true, Claims: map[string]any{"tenant": "brand"}})
+
+	claims, err := NewVerifier(nil).Verify(header, testOrigin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.Audience != testOrigin || claims.Subject != testSubject || claims.PublicKey != testPublicKey {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+
+	if lifetime := claims.ExpiresAt.Sub(claims.IssuedAt); lifetime != DefaultTokenLifetime {
+		t.Fatalf("lifetime %s, want %s", lifetime, DefaultTokenLifetime)
+	}
+
+	if claims.Extra["tenant"] != "brand" {
+		t.Fatalf("unexpected extra claims %v", claims.Extra)
+	}
+}
+
+func TestVerifyErrors(t *testing.T) {
+	header := testHeader(t, &Options{})
+	token, _, _ := strings.Cut(strings.TrimPrefix(header, "vapid t="), ", k=")
+	parts := strings.Split(token, ".")
+
+	keys, _ := GenerateKeys()
+
+	tests := map[string]struct {
+		header string
+		origin string
+		now    time.Time
+		err    error
+	}{
+		"scheme":         {header: "Bearer " + token, err: ErrMalformedHeader},
+		"missing key":    {header: "vapid t=" + token, err: ErrMalformedHeader},
+		"bad key":        {header: "vapid t=" + token + ", k=AAAA", err: ErrMalformedHeader},
+		"other key":      {header: "vapid t=" + token + ", k=" + keys.PublicKeyBase64(), err: ErrSignatureMismatch},
+		"two parts":      {header: "vapid t=" + parts[0] + "." + parts[1] + ", k=" + testPublicKey, err: ErrMalformedToken},
+		"algorithm":      {header: "vapid t=eyJhbGciOiJub25lIn0." + parts[1] + "." + parts[2] + ", k=" + testPublicKey, err: ErrUnsupportedAlgorithm},
+		"tampered":       {header: "vapid t=" + parts[0] + "." + parts[1] + "A." + parts[2] + ", k=" + testPublicKey, err: ErrSignatureMismatch},
+		"audience":       {header: header, origin: "https://updates.push.services.mozilla.com", err: ErrAudienceMismatch},
+		"expired":        {header: header, now: time.Now().Add(DefaultTokenLifetime + time.Minute), err: ErrTokenExpired},
+		"expiry too far": {header: header, now: time.Now().Add(-MaxTokenLifetime), err: ErrExpiryTooFar},
+		"params order":   {header: "vapid k=" + testPublicKey + ",t=" + token},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			origin := tt.origin
+			if origin == "" {
+				origin = testOrigin
+			}
+
+			now := tt.now
+			if now.IsZero() {
+				now = time.Now()
+			}
+
+			_, err := NewVerifier(&VerifierOptions{Now: func() time.Time { return now }}).Verify(tt.header, origin)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestVerifySubject(t *testing.T) {
+	v := NewVerifier(&VerifierOptions{RequireSubject: true})
+	claims := &Claims{Audience: testOrigin, ExpiresAt: time.Now().Add(time.Hour)}
+
+	for _, subject := range []string{"", "ftp://example.com"} {
+		claims.Subject = subject
+
+		if err := v.validate(claims, testOrigin); !errors.Is(err, ErrInvalidSubject) {
+			t.Fatalf("%q: got %v, want %v", subject, err, ErrInvalidSubject)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package pushbell
 
-import "time"
+import (
+	"time"
+
+	"github.com/gootsolution/pushbell/pkg/encryption"
+)
 
 type Urgency string
 
@@ -18,4 +22,9 @@ type Push struct {
 	Plaintext []byte
 	Urgency   Urgency
 	TTL       time.Duration
+	Encoding  encryption.Encoding    // [Optional] Content encoding supported by the user agent, encryption.AES128GCM if empty.
+	Padding   encryption.PaddingFunc // [Optional] Padding strategy, Options.Padding if nil.
+
+	Identity             string // [Optional] ID of the VAPID identity to sign with, see Options.AddIdentity.
+	ApplicationServerKey string // [Optional] applicationServerKey of the subscription, selects the key to sign with, including keys replaced by rotation.
 }
@@ -0,0 +1,42 @@
+package pushbell
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gootsolution/pushbell/pkg/httpclient"
+)
+
+// Result is a push service response to a push message.
+type Result struct {
+	StatusCode int
+	Header     http.Header        // Response headers, nil if the client doesn't report them.
+	Body       []byte             // Response body, truncated to httpclient.DefaultMaxResponseBodySize.
+	Timings    httpclient.Timings // Duration of the request phases, see httpclient.Timings.
+}
+
+// Location returns URL of the created push message resource, see RFC 8030 5.
+func (r *Result) Location() string {
+	return r.Header.Get("Location")
+}
+
+// RetryAfter returns the delay requested by the push service with Retry-After
+// header, usually along with 429 or 503 status codes, and whether it's present.
+func (r *Result) RetryAfter() (time.Duration, bool) {
+	value := r.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	return max(time.Until(date), 0), true
+}
@@ -0,0 +1,95 @@
+package pushbell
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gootsolution/pushbell/pkg/httpclient"
+)
+
+func TestDeliver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		if r.Header.Get("Content-Type") != "application/octet-stream" || r.Header.Get("TTL") != "60" {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		w.Header().Set("Location", "https://push.example.com/message/1")
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = io.WriteString(w, strings.Repeat("x", httpclient.DefaultMaxResponseBodySize+1))
+	}))
+	defer server.Close()
+
+	clients := map[string]httpclient.Client{
+		"fasthttp": httpclient.FastHttp(nil),
+		"std":      httpclient.StdHttp(server.Client()),
+	}
+
+	for name, client := range clients {
+		t.Run(name, func(t *testing.T) {
+			var delivered *Result
+
+			pb, err := NewService(NewOptions().
+				ApplyKeys(testPublicKey, testPrivateKey).
+				SetHttpClient(client).
+				SetStatusCodeValidationFunc(ValidateStatusCode).
+				SetDeliveryCallback(func(_ *Push, result *Result, err error) {
+					if errors.Is(err, ErrPushTooManyRequests) {
+						delivered = result
+					}
+				}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pb.Close()
+
+			push := testPush(t)
+			push.Endpoint = server.URL
+			push.TTL = time.Minute
+
+			result, err := pb.Deliver(push)
+			if !errors.Is(err, ErrPushTooManyRequests) {
+				t.Fatalf("got %v, want %v", err, ErrPushTooManyRequests)
+			}
+
+			if result.Location() != "https://push.example.com/message/1" {
+				t.Fatalf("unexpected Location %q", result.Location())
+			}
+
+			if delay, ok := result.RetryAfter(); !ok || delay != 2*time.Minute {
+				t.Fatalf("unexpected Retry-After %s", delay)
+			}
+
+			if len(result.Body) != httpclient.DefaultMaxResponseBodySize {
+				t.Fatalf("body of %d octets isn't truncated", len(result.Body))
+			}
+
+			if delivered != result || result.Timings.Total <= 0 {
+				t.Fatalf("delivery isn't reported with timings %+v", result.Timings)
+			}
+		})
+	}
+}
+
+func TestResultRetryAfterDate(t *testing.T) {
+	result := &Result{Header: http.Header{}}
+
+	if _, ok := result.RetryAfter(); ok {
+		t.Fatal("missing Retry-After is reported")
+	}
+
+	result.Header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+
+	if delay, ok := result.RetryAfter(); !ok || delay < 59*time.Minute || delay > time.Hour {
+		t.Fatalf("unexpected Retry-After %s", delay)
+	}
+}
@@ -0,0 +1,22 @@
+package pushbell
+
+import "testing"
+
+func TestNewServiceKeyRotation(t *testing.T) {
+	pb, err := NewService(NewOptions().
+		ApplyKeys(testPublicKey, testPrivateKey).
+		SetHttpClient(&recordingClient{}).
+		SetKeyRotationEnabled())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pb.Close()
+
+	if pb.Encryption.PublicKey() == nil {
+		t.Fatal("key rotation doesn't use a shared key")
+	}
+
+	if err = pb.Send(testPush(t)); err != nil {
+		t.Fatal(err)
+	}
+}
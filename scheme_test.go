@@ -0,0 +1,71 @@
+package pushbell
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gootsolution/pushbell/pkg/encryption"
+	"github.com/gootsolution/pushbell/pkg/httpclient"
+	"github.com/gootsolution/pushbell/pkg/vapid"
+)
+
+func TestSendWebPushScheme(t *testing.T) {
+	var received http.Header
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		received = r.Header.Clone()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	clients := map[string]httpclient.Client{
+		"fasthttp": httpclient.FastHttp(nil),
+		"std":      httpclient.StdHttp(server.Client()),
+	}
+
+	for name, client := range clients {
+		t.Run(name, func(t *testing.T) {
+			pb, err := NewService(NewOptions().
+				ApplyKeys(testPublicKey, testPrivateKey).
+				SetVapidScheme(vapid.SchemeWebPush).
+				SetHttpClient(client).
+				SetStatusCodeValidationFunc(ValidateStatusCode))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pb.Close()
+
+			push := testPush(t)
+			push.Endpoint = server.URL
+			push.Encoding = encryption.AESGCM
+
+			if err = pb.Send(push); err != nil {
+				t.Fatal(err)
+			}
+
+			if auth := received.Get("Authorization"); !strings.HasPrefix(auth, "WebPush ey") || strings.Contains(auth, "k=") {
+				t.Fatalf("unexpected Authorization %q", auth)
+			}
+
+			dh, p256ecdsa, ok := strings.Cut(received.Get("Crypto-Key"), ";")
+			if !ok || !base64Param("dh", 65).MatchString(dh) || p256ecdsa != "p256ecdsa="+testPublicKey {
+				t.Fatalf("unexpected Crypto-Key %q", received.Get("Crypto-Key"))
+			}
+
+			if !base64Param("salt", 16).MatchString(received.Get("Encryption")) || received.Get("Content-Encoding") != "aesgcm" {
+				t.Fatalf("unexpected aesgcm headers %v", received)
+			}
+		})
+	}
+}
+
+// base64Param matches a header parameter of URL-safe base64 value of n octets.
+func base64Param(name string, n int) *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf(`^%s=[A-Za-z0-9_-]{%d}$`, name, (n*8+5)/6))
+}
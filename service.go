@@ -15,75 +15,243 @@ import (
 // Service contains all dependencies needed for sending web push notifications.
 type Service struct {
 	Encryption               *encryption.Service
-	Vapid                    *vapid.Service
-	Client                   httpclient.Client
 	StatusCodeValidationFunc StatusCodeValidationFunc
+
+	// Vapid is the service of the default identity at creation, not updated by RotateIdentity.
+	//
+	// Deprecated: pushes are signed with the identities of the service, so changing
+	// Vapid has no effect. Use AddIdentity, RotateIdentity and CurrentKey instead.
+	Vapid *vapid.Service
+
+	// Client is the client set with Options.HttpClient, nil if only Options.HttpClientV2 is set.
+	//
+	// Deprecated: pushes are delivered with the client configured at creation, so
+	// changing Client has no effect. Use Options.SetHttpClient instead.
+	Client httpclient.Client
+
+	options    Options
+	identities *identities
+	client     httpclient.ClientV2
 }
 
 // NewService creates new service with given application server keys and subject.
 func NewService(options *Options) (*Service, error) {
-	vapidService, err := vapid.NewService(
-		options.ApplicationServerPublicKey,
-		options.ApplicationServerPrivateKey,
-		options.ApplicationServerSubject,
-	)
+	publicKey, privateKey, err := options.keys()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create vapid service: %w", err)
+		return nil, fmt.Errorf("failed to load application server keys: %w", err)
 	}
 
-	encryptionService, err := encryption.NewService()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create encryption service: %w", err)
+	ids := newIdentities()
+
+	// The default identity may be omitted if others are configured, then the first one is used.
+	var vapidService *vapid.Service
+
+	if privateKey != "" || options.ApplicationServerSigner != nil || len(options.Identities) == 0 {
+		vapidOptions := options.vapidOptions(&Identity{
+			PublicKey:  publicKey,
+			PrivateKey: privateKey,
+			Signer:     options.ApplicationServerSigner,
+		})
+
+		vapidService, err = vapid.NewServiceWithOptions(vapidOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create vapid service: %w", err)
+		}
+
+		if err = ids.Add("", vapidOptions.Subject, vapidService); err != nil {
+			return nil, fmt.Errorf("failed to add default identity: %w", err)
+		}
 	}
 
+	for _, identity := range options.Identities {
+		if identity.ID == "" {
+			return nil, fmt.Errorf("%w: empty id", ErrUnknownIdentity)
+		}
+
+		vapidOptions := options.vapidOptions(&identity)
+
+		service, err := vapid.NewServiceWithOptions(vapidOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create vapid service of identity %q: %w", identity.ID, err)
+		}
+
+		if err = ids.Add(identity.ID, vapidOptions.Subject, service); err != nil {
+			return nil, fmt.Errorf("failed to add identity: %w", err)
+		}
+
+		if vapidService == nil {
+			vapidService = service
+			ids.defaultID = identity.ID
+		}
+	}
+
+	// Key rotation predates ephemeral keys, and opts in to a shared key.
+	keyMode := options.EncryptionKeyMode
 	if options.KeyRotationInterval != 0 {
-		encryptionService.Rotate(options.KeyRotationInterval)
+		keyMode = encryption.KeyModeShared
+	}
+
+	encryptionService, err := encryption.NewServiceWithOptions(&encryption.Options{
+		KeyMode:             keyMode,
+		KeyPoolSize:         options.EncryptionKeyPoolSize,
+		KeyRotationInterval: options.KeyRotationInterval,
+		Padding:             options.Padding,
+		Random:              options.Random,
+		KeyRotationJitter:   options.KeyRotationJitter,
+		OnKeyRotation:       options.OnKeyRotation,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create encryption service: %w", err)
 	}
 
 	client := options.HttpClient
-	if client == nil {
+	if client == nil && options.HttpClientV2 == nil {
 		client = httpclient.FastHttp(nil)
 	}
 
+	clientV2 := options.HttpClientV2
+	if clientV2 == nil {
+		clientV2 = httpclient.Adapt(client)
+	}
+
+	// Egress is applied to a copy of the client delivering pushes, HttpClientV2 if set.
+	if options.Egress != nil {
+		if clientV2, err = withEgress(clientV2, options.Egress); err != nil {
+			return nil, err
+		}
+	}
+
 	return &Service{
 		Encryption:               encryptionService,
 		Vapid:                    vapidService,
 		Client:                   client,
 		StatusCodeValidationFunc: options.StatusCodeValidationFunc,
+		options:                  *options,
+		identities:               ids,
+		client:                   clientV2,
 	}, nil
 }
 
 // Send sends a WebPush notification with parameters to the specified endpoint.
 func (s *Service) Send(push *Push) error {
+	_, err := s.Deliver(push)
+
+	return err
+}
+
+// Deliver sends a WebPush notification like Send, and returns the push service
+// response. If the status code validation fails, both the result and the error are returned.
+func (s *Service) Deliver(push *Push) (*Result, error) {
 	// Cipher text.
-	body, err := s.Encryption.Encrypt(push.Auth, push.P256DH, push.Plaintext)
+	payload, err := s.Encryption.EncryptMessage(&encryption.Message{
+		Auth:      push.Auth,
+		P256DH:    push.P256DH,
+		Plaintext: push.Plaintext,
+		Encoding:  push.Encoding,
+		Padding:   push.Padding,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt push body: %w", err)
+	}
+
+	// Get auth header of the push identity.
+	vapidService, err := s.vapid(push)
 	if err != nil {
-		return fmt.Errorf("failed to encrypt push body: %w", err)
+		payload.Release()
+
+		return nil, err
 	}
 
-	// Get auth header.
-	authHeader, err := s.Vapid.Header(push.Endpoint)
+	authHeader, err := vapidService.Header(push.Endpoint)
 	if err != nil {
-		return fmt.Errorf("failed to generate vapid auth header: %w", err)
+		payload.Release()
+
+		return nil, fmt.Errorf("failed to generate vapid auth header: %w", err)
 	}
 
 	// Prepare headers for client.
 	headers := &httpclient.Headers{
-		Authorization: authHeader,
-		Urgency:       string(push.Urgency),
-		TTL:           push.TTL,
+		Authorization:   authHeader,
+		Urgency:         string(push.Urgency),
+		TTL:             push.TTL,
+		ContentEncoding: string(payload.Encoding),
+		Encryption:      payload.EncryptionHeader(),
+		CryptoKey:       cryptoKey(payload.CryptoKeyHeader(), vapidService.CryptoKey()),
 	}
 
-	// Request delivery.
-	statusCode, err := s.Client.RequestDelivery(push.Endpoint, headers, body)
+	// Request delivery, the payload buffer is reused once the client is done with it.
+	resp, err := s.client.Do(httpclient.NewRequest(push.Endpoint, headers, payload.Body, payload.Release))
 	if err != nil {
-		return fmt.Errorf("failed to send push request: %w", err)
+		err = fmt.Errorf("failed to send push request: %w", err)
+		s.delivered(push, nil, err)
+
+		return nil, err
+	}
+
+	result := &Result{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       resp.Body,
+		Timings:    resp.Timings,
 	}
 
 	// Check status code if enabled.
 	if s.StatusCodeValidationFunc != nil {
-		return s.StatusCodeValidationFunc(statusCode)
+		err = s.StatusCodeValidationFunc(resp.StatusCode)
+	}
+
+	s.delivered(push, result, err)
+
+	return result, err
+}
+
+// delivered reports the delivery attempt to Options.OnDelivery if set.
+func (s *Service) delivered(push *Push, result *Result, err error) {
+	if s.options.OnDelivery != nil {
+		s.options.OnDelivery(push, result, err)
+	}
+}
+
+// Close stops background work of the service, such as key generation and rotation.
+// Call it once the service is no longer used, otherwise its goroutines keep running.
+func (s *Service) Close() {
+	s.Encryption.Close()
+}
+
+// cryptoKey joins parameters of Crypto-Key header, such as "dh" of aesgcm encoding
+// and "p256ecdsa" of the legacy VAPID scheme.
+func cryptoKey(params ...string) string {
+	var header string
+
+	for _, param := range params {
+		if param == "" {
+			continue
+		}
+
+		if header != "" {
+			header += ";"
+		}
+
+		header += param
+	}
+
+	return header
+}
+
+// withEgress returns a copy of the client with proxy and TLS settings, the
+// client must be one of httpclient package.
+func withEgress(client httpclient.ClientV2, egress *httpclient.Egress) (httpclient.ClientV2, error) {
+	configurable, ok := client.(interface {
+		WithEgress(egress *httpclient.Egress) (httpclient.ClientV2, error)
+	})
+	if !ok {
+		return nil, fmt.Errorf("failed to configure egress: %w", httpclient.ErrEgressUnsupported)
+	}
+
+	configured, err := configurable.WithEgress(egress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure egress: %w", err)
 	}
 
-	return nil
+	return configured, nil
 }
@@ -0,0 +1,67 @@
+package pushbell
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gootsolution/pushbell/pkg/httpclient"
+)
+
+func BenchmarkSend(b *testing.B) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	uaPrivateKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	authSecret := make([]byte, 16)
+	_, _ = rand.Read(authSecret)
+
+	push := &Push{
+		Endpoint:  server.URL + "/push/subscription",
+		Auth:      base64.RawURLEncoding.EncodeToString(authSecret),
+		P256DH:    base64.RawURLEncoding.EncodeToString(uaPrivateKey.PublicKey().Bytes()),
+		Plaintext: []byte(`{"title":"Hello","body":"When I grow up, I want to be a watermelon"}`),
+	}
+
+	clients := []struct {
+		name   string
+		client httpclient.Client
+	}{
+		{"fasthttp", httpclient.FastHttp(nil)},
+		{"std", httpclient.StdHttp(server.Client())},
+	}
+
+	for _, c := range clients {
+		b.Run(c.name, func(b *testing.B) {
+			opts := NewOptions().
+				ApplyKeys(testPublicKey, testPrivateKey).
+				SetHttpClient(c.client).
+				SetStatusCodeValidationFunc(ValidateStatusCode)
+
+			pb, err := NewService(opts)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer pb.Close()
+
+			b.ReportAllocs()
+
+			for b.Loop() {
+				if err = pb.Send(push); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
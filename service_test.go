@@ -15,6 +15,7 @@ func ExampleNewService() {
 	if err != nil {
 		panic(err)
 	}
+	defer pb.Close()
 
 	subscriptionEndpoint := "https://fcm.googleapis.com/fcm/send/e2CN0r8ft38:APA91bES3NaBHe_GgsRp_3Ir7f18L38wA5XYRoqZCbjMPEWnkKa07uxheWE5MGZncsPOr0_34zLaFljVqmNqW76KhPSrjdy_pdInnHPEIYAZpdcIYk8oIfo1F_84uKMSqIDXRhngL76S"
 	subscriptionAuth := "rm_owGF0xliyVXsrZk1LzQ"